@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,13 +12,27 @@ import (
 
 	"github.com/zricethezav/gitleaks/v8/config"
 	"github.com/zricethezav/gitleaks/v8/detect"
-	"github.com/zricethezav/gitleaks/v8/report"
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+	"github.com/zricethezav/gitleaks/v8/verify"
 )
 
 func init() {
 	rootCmd.AddCommand(detectCmd)
 	detectCmd.Flags().String("log-opts", "", "git log options")
+	detectCmd.Flags().String("base-ref", "", "only scan commits that are not reachable from this ref, e.g. the target branch of a pull request")
+	detectCmd.Flags().String("head-ref", "", "only scan the history of this ref (default: HEAD if --base-ref is set, otherwise all refs)")
+	detectCmd.Flags().String("since", "", "only scan commits more recent than this date, in any format git log --since accepts")
+	detectCmd.Flags().String("until", "", "only scan commits older than this date, in any format git log --until accepts")
+	detectCmd.Flags().Int("max-commits", 0, "only scan this many of the most recent commits (default: no limit)")
+	detectCmd.Flags().Bool("group-hunks", false, "scan the lines a commit adds to a file as one fragment so secrets split across diff hunks are detected")
+	detectCmd.Flags().Bool("check-head", false, "report whether each secret is still present on the default branch and the commit that removed it")
+	detectCmd.Flags().String("cache-dir", "", "directory of the scan cache, git scans only scan commits not scanned by earlier runs with the same config (default: no cache)")
+	detectCmd.Flags().String("git-engine", "log", "how git history is scanned: log scans the patches of git log -p, blob scans each unique blob of the history once")
+	detectCmd.Flags().String("git-backend", "cmd", "how git history is read: cmd runs the git binary, native reads the objects under .git without git and implies --git-engine=blob")
 	detectCmd.Flags().Bool("no-git", false, "treat git repo as a regular directory and scan those files, --log-opts has no effect on the scan when --no-git is set")
+	detectCmd.Flags().Int("max-target-megabytes", 0, "files larger than this will be skipped when --no-git is set (default: no limit)")
+	detectCmd.Flags().Bool("scan-archives", false, "scan the contents of zip, jar, tar and gzip archives when --no-git is set")
+	detectCmd.Flags().Int("max-archive-depth", 5, "maximum number of nested archives to open when --scan-archives is set")
 }
 
 var detectCmd = &cobra.Command{
@@ -29,9 +44,8 @@ var detectCmd = &cobra.Command{
 func runDetect(cmd *cobra.Command, args []string) {
 	initConfig()
 	var (
-		vc       config.ViperConfig
-		findings []report.Finding
-		err      error
+		vc  config.ViperConfig
+		err error
 	)
 
 	// Load config
@@ -70,6 +84,68 @@ func runDetect(cmd *cobra.Command, args []string) {
 	if detector.Redact, err = cmd.Flags().GetBool("redact"); err != nil {
 		log.Fatal().Err(err)
 	}
+	// set number of workers
+	if detector.Threads, err = cmd.Flags().GetInt("threads"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// set how deep encoded content is decoded
+	if detector.MaxDecodeDepth, err = cmd.Flags().GetInt("max-decode-depth"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// verify secrets with the provider of the rule that found them
+	verifySecrets, err := cmd.Flags().GetBool("verify")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if verifySecrets {
+		if detector.Verifiers, err = verify.NewVerifiers(cfg.VerifyEndpoints); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load verifiers")
+		}
+	}
+	// scan the hunks of a file together
+	if detector.GroupHunks, err = cmd.Flags().GetBool("group-hunks"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// look up the secrets of git findings on the default branch
+	if detector.CheckHead, err = cmd.Flags().GetBool("check-head"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// set max target size
+	if detector.MaxTargetMegaBytes, err = cmd.Flags().GetInt("max-target-megabytes"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// set archive scanning
+	if detector.ScanArchives, err = cmd.Flags().GetBool("scan-archives"); err != nil {
+		log.Fatal().Err(err)
+	}
+	if detector.MaxArchiveDepth, err = cmd.Flags().GetInt("max-archive-depth"); err != nil {
+		log.Fatal().Err(err)
+	}
+
+	// load baseline, findings present in the baseline will not be reported
+	baselinePath, err := cmd.Flags().GetString("baseline-path")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if err = detector.AddBaseline(baselinePath); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load baseline")
+	}
+
+	// load .gitleaksignore, findings with a listed fingerprint will not be reported.
+	// if the path is not set, then use {source}/.gitleaksignore which may not exist.
+	gitleaksIgnorePath, err := cmd.Flags().GetString("gitleaks-ignore-path")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if gitleaksIgnorePath != "" {
+		if err = detector.AddGitleaksIgnore(gitleaksIgnorePath); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load .gitleaksignore")
+		}
+	} else if fileInfo, err := os.Stat(source); err == nil && fileInfo.IsDir() {
+		if err = detector.AddGitleaksIgnore(source); err != nil && !os.IsNotExist(err) {
+			log.Fatal().Err(err).Msg("Failed to load .gitleaksignore")
+		}
+	}
 
 	// set exit code
 	exitCode, err := cmd.Flags().GetInt("exit-code")
@@ -85,12 +161,17 @@ func runDetect(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err)
 	}
 
-	// start the detector scan
+	// start the detector scan, findings are written to the report as they are found
+	sink := newFindingSink(cmd, cfg)
+	sink.attach(detector)
+	ctx, cancel := scanContext(cmd)
+	defer cancel()
+	gitFailed := false
 	if noGit {
-		findings, err = detector.DetectFiles(source)
-		if err != nil {
+		err = detector.DetectFilesStream(ctx, source, sink.add)
+		if err != nil && ctx.Err() == nil {
 			// don't exit on error, just log it
-			log.Error().Err(err)
+			log.Error().Err(err).Msg("")
 		}
 
 	} else {
@@ -98,31 +179,106 @@ func runDetect(cmd *cobra.Command, args []string) {
 		if err != nil {
 			log.Fatal().Err(err)
 		}
-		findings, err = detector.DetectGit(source, logOpts, detect.DetectType)
+		if detector.LogRange.BaseRef, err = cmd.Flags().GetString("base-ref"); err != nil {
+			log.Fatal().Err(err)
+		}
+		if detector.LogRange.HeadRef, err = cmd.Flags().GetString("head-ref"); err != nil {
+			log.Fatal().Err(err)
+		}
+		if detector.LogRange.Since, err = cmd.Flags().GetString("since"); err != nil {
+			log.Fatal().Err(err)
+		}
+		if detector.LogRange.Until, err = cmd.Flags().GetString("until"); err != nil {
+			log.Fatal().Err(err)
+		}
+		if detector.LogRange.MaxCommits, err = cmd.Flags().GetInt("max-commits"); err != nil {
+			log.Fatal().Err(err)
+		}
+		if logOpts != "" && !detector.LogRange.IsZero() {
+			log.Fatal().Msg("--log-opts cannot be combined with --base-ref, --head-ref, --since, --until or --max-commits")
+		}
+		cacheDir, err := cmd.Flags().GetString("cache-dir")
+		if err != nil {
+			log.Fatal().Err(err)
+		}
+		gitEngine, err := cmd.Flags().GetString("git-engine")
 		if err != nil {
+			log.Fatal().Err(err)
+		}
+		gitBackend, err := cmd.Flags().GetString("git-backend")
+		if err != nil {
+			log.Fatal().Err(err)
+		}
+		var repo git.Repository
+		switch gitBackend {
+		case "cmd":
+			repo = git.NewCmdRepository(source, detector.GitStderrClassifier)
+		case "native":
+			// patches are only produced by git log, the native backend
+			// reads blobs
+			if gitEngine != "blob" && cmd.Flags().Changed("git-engine") {
+				log.Warn().Msgf("--git-engine=%s is not supported by --git-backend=native, using blob", gitEngine)
+			}
+			gitEngine = "blob"
+			if repo, err = git.NewNativeRepository(source); err != nil {
+				log.Fatal().Err(err).Msg("Failed to open git repository")
+			}
+		default:
+			log.Fatal().Msgf("unknown git backend %s, expected cmd or native", gitBackend)
+		}
+		switch gitEngine {
+		case "log":
+			// the commit range is only checked when it is used, the
+			// blob engine and the native backend ignore it
+			if err = detector.LogRange.Validate(ctx, source); err != nil {
+				log.Fatal().Err(err).Msg("Invalid commit range")
+			}
+			// only scan commits not scanned by earlier runs, this must
+			// come after the options of the detector are set
+			if err = detector.UseScanCache(cacheDir, source); err != nil {
+				log.Fatal().Err(err).Msg("Failed to load scan cache")
+			}
+			err = detector.DetectGitStream(ctx, source, logOpts, detect.DetectType, sink.add)
+		case "blob":
+			if logOpts != "" || cacheDir != "" || !detector.LogRange.IsZero() {
+				log.Warn().Msg("--log-opts, --cache-dir and the commit range flags have no effect with --git-engine=blob")
+			}
+			// blobs are scanned whole, there are no hunks to group
+			if detector.GroupHunks || detector.CheckHead {
+				log.Warn().Msg("--group-hunks and --check-head have no effect with --git-engine=blob")
+			}
+			err = detector.DetectGitBlobsStream(ctx, repo, sink.add)
+		default:
+			log.Fatal().Msgf("unknown git engine %s, expected log or blob", gitEngine)
+		}
+		if err != nil && ctx.Err() == nil {
 			// don't exit on error, just log it
-			log.Error().Err(err)
+			log.Error().Err(err).Msg("")
 		}
+		// git failing means part of the history was not scanned
+		var gitErr *git.CommandError
+		gitFailed = errors.As(err, &gitErr)
 	}
+	incomplete := ctx.Err() != nil || gitFailed
+	sink.close(incomplete)
 
 	// log info about the scan
 	log.Info().Msgf("scan completed in %s", time.Since(start))
-	if len(findings) != 0 {
-		log.Warn().Msgf("leaks found: %d", len(findings))
+	if sink.leaks != 0 {
+		log.Warn().Msgf("leaks found: %d", sink.leaks)
 	} else {
 		log.Info().Msg("no leaks found")
 	}
 
-	// write report if desired
-	reportPath, _ := cmd.Flags().GetString("report-path")
-	ext, _ := cmd.Flags().GetString("report-format")
-	if reportPath != "" {
-		if err = report.Write(findings, cfg, ext, reportPath); err != nil {
-			log.Fatal().Err(err)
-		}
+	if gitFailed {
+		log.Warn().Msg("git failed before the scan completed, findings gathered so far have been reported")
+		os.Exit(1)
 	}
-
-	if len(findings) != 0 {
+	if incomplete {
+		log.Warn().Msgf("scan stopped before completion (%s), findings gathered so far have been reported", ctx.Err())
+		os.Exit(incompleteExitCode(ctx.Err()))
+	}
+	if sink.leaks != 0 {
 		os.Exit(exitCode)
 	}
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,11 +12,13 @@ import (
 
 	"github.com/zricethezav/gitleaks/v8/config"
 	"github.com/zricethezav/gitleaks/v8/detect"
-	"github.com/zricethezav/gitleaks/v8/report"
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+	"github.com/zricethezav/gitleaks/v8/verify"
 )
 
 func init() {
 	protectCmd.Flags().Bool("staged", false, "detect secrets in a --staged state")
+	protectCmd.Flags().Bool("group-hunks", false, "scan the lines added to a file as one fragment so secrets split across diff hunks are detected")
 	rootCmd.AddCommand(protectCmd)
 }
 
@@ -65,6 +68,54 @@ func runProtect(cmd *cobra.Command, args []string) {
 	if detector.Redact, err = cmd.Flags().GetBool("redact"); err != nil {
 		log.Fatal().Err(err)
 	}
+	// set number of workers
+	if detector.Threads, err = cmd.Flags().GetInt("threads"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// set how deep encoded content is decoded
+	if detector.MaxDecodeDepth, err = cmd.Flags().GetInt("max-decode-depth"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// verify secrets with the provider of the rule that found them
+	verifySecrets, err := cmd.Flags().GetBool("verify")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if verifySecrets {
+		if detector.Verifiers, err = verify.NewVerifiers(cfg.VerifyEndpoints); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load verifiers")
+		}
+	}
+
+	// scan the hunks of a file together
+	if detector.GroupHunks, err = cmd.Flags().GetBool("group-hunks"); err != nil {
+		log.Fatal().Err(err)
+	}
+
+	// load baseline, findings present in the baseline will not be reported
+	baselinePath, err := cmd.Flags().GetString("baseline-path")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if err = detector.AddBaseline(baselinePath); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load baseline")
+	}
+
+	// load .gitleaksignore, findings with a listed fingerprint will not be reported.
+	// if the path is not set, then use {source}/.gitleaksignore which may not exist.
+	gitleaksIgnorePath, err := cmd.Flags().GetString("gitleaks-ignore-path")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if gitleaksIgnorePath != "" {
+		if err = detector.AddGitleaksIgnore(gitleaksIgnorePath); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load .gitleaksignore")
+		}
+	} else if fileInfo, err := os.Stat(source); err == nil && fileInfo.IsDir() {
+		if err = detector.AddGitleaksIgnore(source); err != nil && !os.IsNotExist(err) {
+			log.Fatal().Err(err).Msg("Failed to load .gitleaksignore")
+		}
+	}
 
 	// get log options for git scan
 	logOpts, err := cmd.Flags().GetString("log-opts")
@@ -72,34 +123,43 @@ func runProtect(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err)
 	}
 
-	// start git scan
-	var findings []report.Finding
+	// start git scan, findings are written to the report as they are found
+	sink := newFindingSink(cmd, cfg)
+	sink.attach(detector)
+	ctx, cancel := scanContext(cmd)
+	defer cancel()
 	if staged {
-		findings, err = detector.DetectGit(source, logOpts, detect.ProtectStagedType)
+		err = detector.DetectGitStream(ctx, source, logOpts, detect.ProtectStagedType, sink.add)
 	} else {
-		findings, err = detector.DetectGit(source, logOpts, detect.ProtectType)
+		err = detector.DetectGitStream(ctx, source, logOpts, detect.ProtectType, sink.add)
 	}
-	if err != nil {
+	if err != nil && ctx.Err() == nil {
 		// don't exit on error, just log it
-		log.Error().Err(err)
+		log.Error().Err(err).Msg("")
 	}
+	// git failing means part of the changes were not scanned
+	var gitErr *git.CommandError
+	gitFailed := errors.As(err, &gitErr)
+	incomplete := ctx.Err() != nil || gitFailed
+	sink.close(incomplete)
 
 	// log info about the scan
 	log.Info().Msgf("scan completed in %s", time.Since(start))
-	if len(findings) != 0 {
-		log.Warn().Msgf("leaks found: %d", len(findings))
+	if sink.leaks != 0 {
+		log.Warn().Msgf("leaks found: %d", sink.leaks)
 	} else {
 		log.Info().Msg("no leaks found")
 	}
 
-	reportPath, _ := cmd.Flags().GetString("report-path")
-	ext, _ := cmd.Flags().GetString("report-format")
-	if reportPath != "" {
-		if err = report.Write(findings, cfg, ext, reportPath); err != nil {
-			log.Fatal().Err(err)
-		}
+	if gitFailed {
+		log.Warn().Msg("git failed before the scan completed, findings gathered so far have been reported")
+		os.Exit(1)
+	}
+	if incomplete {
+		log.Warn().Msgf("scan stopped before completion (%s), findings gathered so far have been reported", ctx.Err())
+		os.Exit(incompleteExitCode(ctx.Err()))
 	}
-	if len(findings) != 0 {
+	if sink.leaks != 0 {
 		os.Exit(exitCode)
 	}
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/detect"
+)
+
+func init() {
+	rootCmd.AddCommand(redactCmd)
+	redactCmd.Flags().String("mask", detect.DefaultRedactMask, "text that replaces each secret")
+	redactCmd.Flags().String("file-name", "", "file name used to match path based rules and allowlists when reading stdin")
+}
+
+var redactCmd = &cobra.Command{
+	Use:   "redact [file...]",
+	Short: "write files or stdin to stdout with secrets masked",
+	Run:   runRedact,
+}
+
+func runRedact(cmd *cobra.Command, args []string) {
+	initConfig()
+	var (
+		vc  config.ViperConfig
+		err error
+	)
+
+	// Load config
+	if err = viper.Unmarshal(&vc); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+	cfg, err := vc.Translate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+
+	// Setup detector
+	detector := detect.NewDetector(cfg)
+	detector.Config.Path, err = cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	// set how deep encoded content is decoded
+	if detector.MaxDecodeDepth, err = cmd.Flags().GetInt("max-decode-depth"); err != nil {
+		log.Fatal().Err(err)
+	}
+	mask, err := cmd.Flags().GetString("mask")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+
+	// read stdin if no files are given
+	if len(args) == 0 {
+		fileName, err := cmd.Flags().GetString("file-name")
+		if err != nil {
+			log.Fatal().Err(err)
+		}
+		if err = detector.RedactStream(cmd.InOrStdin(), os.Stdout, fileName, mask); err != nil {
+			log.Fatal().Err(err).Msg("Failed to redact stdin")
+		}
+		return
+	}
+
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to open file")
+		}
+		err = detector.RedactStream(f, os.Stdout, path, mask)
+		f.Close()
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed to redact %s", path)
+		}
+	}
+}
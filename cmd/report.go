@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/detect"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// findingSink receives findings from the detector as they are found. It keeps
+// count of the findings, or of their groups with --group-by-secret, and writes
+// them to the report if one was requested.
+// Findings suppressed by gitleaks:allow comments are written to the report
+// as such and are not counted as leaks.
+type findingSink struct {
+	leaks  int
+	writer report.StreamWriter
+
+	// groups counts the groups of findings with --group-by-secret, nil
+	// otherwise
+	groups *report.GroupCounter
+}
+
+// newFindingSink creates the report file set by --report-path, if any.
+// With --group-by-secret findings are grouped when the report is closed.
+// Suppressed findings are handed to the sink by the detector once the sink
+// is attached to it.
+func newFindingSink(cmd *cobra.Command, cfg config.Config) *findingSink {
+	sink := &findingSink{}
+	reportPath, _ := cmd.Flags().GetString("report-path")
+	ext, _ := cmd.Flags().GetString("report-format")
+	groupBySecret, _ := cmd.Flags().GetBool("group-by-secret")
+	var opts report.Options
+	opts.Verify, _ = cmd.Flags().GetBool("verify")
+	// only detect has --check-head
+	opts.CheckHead, _ = cmd.Flags().GetBool("check-head")
+	if groupBySecret {
+		sink.groups = &report.GroupCounter{}
+	}
+	if reportPath != "" {
+		w, err := report.NewStreamWriter(cfg, ext, reportPath, opts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create report")
+		}
+		if groupBySecret {
+			w = report.NewGroupingStreamWriter(w)
+		}
+		sink.writer = w
+	}
+	return sink
+}
+
+// attach makes the detector hand the findings it suppresses to the sink
+func (s *findingSink) attach(detector *detect.Detector) {
+	detector.OnSuppressed = s.addSuppressed
+}
+
+// add is passed to the detector's streaming functions as the onFinding callback
+func (s *findingSink) add(finding report.Finding) error {
+	if s.groups != nil {
+		s.leaks = s.groups.Add(finding)
+	} else {
+		s.leaks++
+	}
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.WriteFinding(finding)
+}
+
+// addSuppressed is passed to the detector as the OnSuppressed callback
+func (s *findingSink) addSuppressed(finding report.Finding) error {
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.WriteFinding(finding)
+}
+
+// close finishes writing the report. If incomplete is set the report is
+// marked as the result of a scan that did not finish.
+func (s *findingSink) close(incomplete bool) {
+	if s.writer == nil {
+		return
+	}
+	if incomplete {
+		s.writer.MarkIncomplete()
+	}
+	if err := s.writer.Close(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to write report")
+	}
+}
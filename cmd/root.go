@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -42,9 +45,16 @@ func init() {
 	rootCmd.PersistentFlags().StringP("source", "s", ".", "path to source (default: $PWD)")
 	rootCmd.PersistentFlags().StringP("report-path", "r", "", "report file")
 	rootCmd.PersistentFlags().StringP("report-format", "f", "json", "output format (json, csv, sarif)")
+	rootCmd.PersistentFlags().StringP("baseline-path", "b", "", "path to baseline with issues that can be ignored")
+	rootCmd.PersistentFlags().String("gitleaks-ignore-path", "", "path to .gitleaksignore file or folder containing one (default: (--source/-s)/.gitleaksignore)")
 	rootCmd.PersistentFlags().StringP("log-level", "l", "info", "log level (debug, info, warn, error, fatal)")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "show verbose output from scan")
 	rootCmd.PersistentFlags().Bool("redact", false, "redact secrets from logs and stdout")
+	rootCmd.PersistentFlags().Int("threads", runtime.NumCPU(), "number of workers used to read files and scan for secrets")
+	rootCmd.PersistentFlags().Int("max-decode-depth", 0, "number of times base64 and hex encoded content is decoded and scanned again (default: 0, no decoding)")
+	rootCmd.PersistentFlags().Bool("verify", false, "check whether secrets are live with the provider of the rule that found them")
+	rootCmd.PersistentFlags().Bool("group-by-secret", false, "report findings with the same rule and secret as one finding listing every occurrence")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "stop the scan and write a partial report after this duration, e.g. 30m (default: no timeout)")
 	err := viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
 	if err != nil {
 		log.Fatal().Msgf("err binding config %s", err.Error())
@@ -73,6 +83,36 @@ func initLog() {
 	}
 }
 
+const (
+	// exit code 130: the scan was interrupted, the report is incomplete
+	interruptedExitCode = 130
+
+	// exit code 124: --timeout expired, the report is incomplete
+	timeoutExitCode = 124
+)
+
+// incompleteExitCode returns the exit code of a scan stopped early because
+// its context ended with err
+func incompleteExitCode(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return timeoutExitCode
+	}
+	return interruptedExitCode
+}
+
+// scanContext returns the context used to run a scan. It is cancelled when
+// gitleaks receives an interrupt signal or when --timeout expires.
+func scanContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	if timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
 func initConfig() {
 	fmt.Fprint(os.Stderr, banner)
 	cfgPath, err := rootCmd.Flags().GetString("config")
@@ -126,8 +166,10 @@ func initConfig() {
 	}
 }
 
-func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+// Execute runs gitleaks. Scans are stopped when ctx is cancelled and
+// a partial report is written.
+func Execute(ctx context.Context) {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		if strings.Contains(err.Error(), "unknown flag") {
 			// exit code 126: Command invoked cannot execute
 			os.Exit(126)
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/detect"
+	"github.com/zricethezav/gitleaks/v8/verify"
+)
+
+func init() {
+	rootCmd.AddCommand(stdinCmd)
+	stdinCmd.Flags().String("file-name", "", "file name reported for findings in the piped content, path based rules and allowlists are matched against it")
+}
+
+var stdinCmd = &cobra.Command{
+	Use:   "stdin",
+	Short: "detect secrets from stdin",
+	Run:   runStdin,
+}
+
+func runStdin(cmd *cobra.Command, args []string) {
+	initConfig()
+	var (
+		vc  config.ViperConfig
+		err error
+	)
+
+	// Load config
+	if err = viper.Unmarshal(&vc); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+	cfg, err := vc.Translate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+	if cfg.Path, err = cmd.Flags().GetString("config"); err != nil {
+		log.Fatal().Err(err)
+	}
+
+	// start timer
+	start := time.Now()
+
+	// Setup detector
+	detector := detect.NewDetector(cfg)
+	// set verbose flag
+	if detector.Verbose, err = cmd.Flags().GetBool("verbose"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// set redact flag
+	if detector.Redact, err = cmd.Flags().GetBool("redact"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// set how deep encoded content is decoded
+	if detector.MaxDecodeDepth, err = cmd.Flags().GetInt("max-decode-depth"); err != nil {
+		log.Fatal().Err(err)
+	}
+	// verify secrets with the provider of the rule that found them
+	verifySecrets, err := cmd.Flags().GetBool("verify")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if verifySecrets {
+		if detector.Verifiers, err = verify.NewVerifiers(cfg.VerifyEndpoints); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load verifiers")
+		}
+	}
+
+	// load baseline, findings present in the baseline will not be reported
+	baselinePath, err := cmd.Flags().GetString("baseline-path")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if err = detector.AddBaseline(baselinePath); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load baseline")
+	}
+
+	// load .gitleaksignore, findings with a listed fingerprint will not be reported.
+	// piped content has no source directory to look for a .gitleaksignore in.
+	gitleaksIgnorePath, err := cmd.Flags().GetString("gitleaks-ignore-path")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	if gitleaksIgnorePath != "" {
+		if err = detector.AddGitleaksIgnore(gitleaksIgnorePath); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load .gitleaksignore")
+		}
+	}
+
+	// set exit code
+	exitCode, err := cmd.Flags().GetInt("exit-code")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+	fileName, err := cmd.Flags().GetString("file-name")
+	if err != nil {
+		log.Fatal().Err(err)
+	}
+
+	// scan the piped content in chunks as it is read, findings are written to
+	// the report as they are found
+	sink := newFindingSink(cmd, cfg)
+	sink.attach(detector)
+	ctx, cancel := scanContext(cmd)
+	defer cancel()
+	if err = detector.DetectReaderStream(ctx, cmd.InOrStdin(), fileName, sink.add); err != nil && ctx.Err() == nil {
+		// don't exit on error, just log it
+		log.Error().Err(err).Msg("")
+	}
+	incomplete := ctx.Err() != nil
+	sink.close(incomplete)
+
+	// log info about the scan
+	log.Info().Msgf("scan completed in %s", time.Since(start))
+	if sink.leaks != 0 {
+		log.Warn().Msgf("leaks found: %d", sink.leaks)
+	} else {
+		log.Info().Msg("no leaks found")
+	}
+
+	if incomplete {
+		log.Warn().Msgf("scan stopped before completion (%s), findings gathered so far have been reported", ctx.Err())
+		os.Exit(incompleteExitCode(ctx.Err()))
+	}
+	if sink.leaks != 0 {
+		os.Exit(exitCode)
+	}
+}
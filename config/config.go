@@ -24,6 +24,12 @@ type ViperConfig struct {
 		Keywords    []string
 		Path        string
 		Tags        []string
+		SkipReport  bool
+
+		Required []struct {
+			ID          string
+			WithinLines *int
+		}
 
 		Allowlist struct {
 			Regexes   []string
@@ -38,6 +44,9 @@ type ViperConfig struct {
 		Commits   []string
 		StopWords []string
 	}
+	Verify struct {
+		Endpoints map[string]string
+	}
 }
 
 // Config is a configuration struct that contains rules and an allowlist if present.
@@ -47,6 +56,10 @@ type Config struct {
 	Rules       []*Rule
 	Allowlist   Allowlist
 	Keywords    []string
+
+	// VerifyEndpoints overrides the endpoint used to verify the
+	// secrets of a rule, keyed by rule ID
+	VerifyEndpoints map[string]string
 }
 
 func (vc *ViperConfig) Translate() (Config, error) {
@@ -88,6 +101,13 @@ func (vc *ViperConfig) Translate() (Config, error) {
 		} else {
 			configPathRegex = regexp.MustCompile(r.Path)
 		}
+		var required []*Required
+		for _, req := range r.Required {
+			required = append(required, &Required{
+				RuleID:      req.ID,
+				WithinLines: req.WithinLines,
+			})
+		}
 		r := &Rule{
 			Description: r.Description,
 			RuleID:      r.ID,
@@ -97,6 +117,8 @@ func (vc *ViperConfig) Translate() (Config, error) {
 			Entropy:     r.Entropy,
 			Tags:        r.Tags,
 			Keywords:    r.Keywords,
+			SkipReport:  r.SkipReport,
+			Required:    required,
 			Allowlist: Allowlist{
 				Regexes:   allowlistRegexes,
 				Paths:     allowlistPaths,
@@ -109,6 +131,9 @@ func (vc *ViperConfig) Translate() (Config, error) {
 		}
 		rules = append(rules, r)
 	}
+	if err := validateRequired(rules); err != nil {
+		return Config{}, err
+	}
 	var allowlistRegexes []*regexp.Regexp
 	for _, a := range vc.Allowlist.Regexes {
 		allowlistRegexes = append(allowlistRegexes, regexp.MustCompile(a))
@@ -126,6 +151,29 @@ func (vc *ViperConfig) Translate() (Config, error) {
 			Commits:   vc.Allowlist.Commits,
 			StopWords: vc.Allowlist.StopWords,
 		},
-		Keywords: keywords,
+		Keywords:        keywords,
+		VerifyEndpoints: vc.Verify.Endpoints,
 	}, nil
 }
+
+// validateRequired checks that the rules required by composite rules exist
+func validateRequired(rules []*Rule) error {
+	ruleIDs := make(map[string]bool)
+	for _, r := range rules {
+		ruleIDs[r.RuleID] = true
+	}
+	for _, r := range rules {
+		for _, req := range r.Required {
+			if req.RuleID == r.RuleID {
+				return fmt.Errorf("%s cannot require itself", r.Description)
+			}
+			if !ruleIDs[req.RuleID] {
+				return fmt.Errorf("%s requires unknown rule %s", r.Description, req.RuleID)
+			}
+			if req.WithinLines != nil && *req.WithinLines < 0 {
+				return fmt.Errorf("%s invalid withinLines %d for required rule %s", r.Description, *req.WithinLines, req.RuleID)
+			}
+		}
+	}
+	return nil
+}
@@ -89,6 +89,60 @@ func TestTranslate(t *testing.T) {
 				},
 			},
 		},
+		{
+			cfgName: "verify",
+			cfg: Config{
+				Rules: []*Rule{
+					{
+						Description: "GitHub Personal Access Token",
+						Regex:       regexp.MustCompile(`ghp_[0-9a-zA-Z]{36}`),
+						RuleID:      "github-pat",
+						Allowlist:   Allowlist{},
+						Tags:        []string{},
+						Keywords:    []string{},
+					},
+				},
+				VerifyEndpoints: map[string]string{
+					"github-pat": "http://localhost:8080/user",
+				},
+			},
+		},
+		{
+			cfgName: "composite",
+			cfg: Config{
+				Rules: []*Rule{
+					{
+						Description: "AWS Access Key Pair",
+						Regex:       regexp.MustCompile("(A3T[A-Z0-9]|AKIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|ASIA)[A-Z0-9]{16}"),
+						RuleID:      "aws-access-key-pair",
+						Allowlist:   Allowlist{},
+						Tags:        []string{"key", "AWS"},
+						Keywords:    []string{},
+						Required: []*Required{
+							{
+								RuleID:      "aws-secret-access-key",
+								WithinLines: intPtr(2),
+							},
+						},
+					},
+					{
+						Description: "AWS Secret Access Key",
+						Regex:       regexp.MustCompile(`(?i)aws_?secret_?access_?key\s*[=:]\s*["']?([0-9a-zA-Z/+]{40})`),
+						RuleID:      "aws-secret-access-key",
+						Allowlist:   Allowlist{},
+						SecretGroup: 1,
+						Tags:        []string{},
+						Keywords:    []string{},
+						SkipReport:  true,
+					},
+				},
+			},
+		},
+		{
+			cfgName:   "bad_composite",
+			cfg:       Config{},
+			wantError: fmt.Errorf("AWS Access Key Pair requires unknown rule aws-secret-access-key"),
+		},
 		{
 			cfgName:   "bad_entropy_group",
 			cfg:       Config{},
@@ -120,5 +174,10 @@ func TestTranslate(t *testing.T) {
 		}
 
 		assert.Equal(t, cfg.Rules, tt.cfg.Rules)
+		assert.Equal(t, cfg.VerifyEndpoints, tt.cfg.VerifyEndpoints)
 	}
 }
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Hash returns a digest of the rules and allowlists of the config. It
+// changes whenever the config is edited in a way that can change the
+// findings of a scan.
+func (c Config) Hash() string {
+	h := sha256.New()
+	writeAllowlist(h, c.Allowlist)
+	for _, r := range c.Rules {
+		fmt.Fprintf(h, "rule %q %q %v %d %q %q %q %q %v\n",
+			r.RuleID, r.Description, r.Entropy, r.SecretGroup,
+			regexString(r.Regex), regexString(r.Path), r.Tags, r.Keywords, r.SkipReport)
+		for _, required := range r.Required {
+			withinLines := -1
+			if required.WithinLines != nil {
+				withinLines = *required.WithinLines
+			}
+			fmt.Fprintf(h, "required %q %d\n", required.RuleID, withinLines)
+		}
+		writeAllowlist(h, r.Allowlist)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeAllowlist(w io.Writer, a Allowlist) {
+	fmt.Fprintf(w, "allowlist %q %q\n", a.Commits, a.StopWords)
+	for _, re := range a.Regexes {
+		fmt.Fprintf(w, "regex %q\n", regexString(re))
+	}
+	for _, re := range a.Paths {
+		fmt.Fprintf(w, "path %q\n", regexString(re))
+	}
+}
+
+func regexString(re *regexp.Regexp) string {
+	if re == nil {
+		return ""
+	}
+	return re.String()
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	newConfig := func() Config {
+		return Config{
+			Rules: []*Rule{
+				{
+					RuleID:   "aws-access-key",
+					Regex:    regexp.MustCompile("AKIA[A-Z0-9]{16}"),
+					Keywords: []string{"akia"},
+				},
+			},
+		}
+	}
+	cfg := newConfig()
+	assert.Equal(t, cfg.Hash(), newConfig().Hash())
+
+	// the path of the config does not change its findings
+	cfg.Path = "other.toml"
+	assert.Equal(t, cfg.Hash(), newConfig().Hash())
+
+	cfg.Rules[0].Regex = regexp.MustCompile("AKIA[A-Z0-9]{12}")
+	assert.NotEqual(t, cfg.Hash(), newConfig().Hash())
+
+	cfg = newConfig()
+	cfg.Allowlist.Paths = []*regexp.Regexp{regexp.MustCompile(`\.md$`)}
+	assert.NotEqual(t, cfg.Hash(), newConfig().Hash())
+
+	cfg = newConfig()
+	cfg.Rules[0].Allowlist.StopWords = []string{"example"}
+	assert.NotEqual(t, cfg.Hash(), newConfig().Hash())
+}
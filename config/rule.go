@@ -40,4 +40,24 @@ type Rule struct {
 	// Allowlist allows a rule to be ignored for specific
 	// regexes, paths, and/or commits
 	Allowlist Allowlist
+
+	// Required lists the rules that must also match near a match of
+	// this rule for it to be reported, making this a composite rule.
+	// The matches of the required rules are reported with the finding.
+	Required []*Required
+
+	// SkipReport is used for rules that are only meant to be required
+	// by composite rules. Their matches are not reported on their own.
+	SkipReport bool
+}
+
+// Required is a rule that must match near the match of a composite rule
+type Required struct {
+	// RuleID is the ID of the required rule
+	RuleID string
+
+	// WithinLines is the maximum number of lines between the match of
+	// the composite rule and the match of the required rule. If nil the
+	// required rule may match anywhere in the same file.
+	WithinLines *int
 }
@@ -0,0 +1,289 @@
+package detect
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+const (
+	// gitleaksAllowBeginSignature and gitleaksAllowEndSignature enclose
+	// a region in which findings are suppressed
+	gitleaksAllowBeginSignature = "gitleaks:allow-begin"
+	gitleaksAllowEndSignature   = "gitleaks:allow-end"
+)
+
+// allowDateLayout is the layout of the until= date of a gitleaks:allow comment
+const allowDateLayout = "2006-01-02"
+
+// allowComment is an inline gitleaks:allow comment, optionally followed by
+// parameters that narrow it down:
+//
+//	gitleaks:allow rule=generic-api-key,aws-access-token until=2027-01-01 reason="test fixture"
+type allowComment struct {
+	// ruleIDs are the rules the comment applies to, all rules if empty
+	ruleIDs []string
+
+	// until is the date after which the comment stops applying, empty if
+	// the comment never expires
+	until string
+
+	reason string
+}
+
+// parseAllowComments returns the gitleaks:allow comments in line
+func parseAllowComments(line string) []allowComment {
+	var comments []allowComment
+	for {
+		i := strings.Index(line, gitleaksAllowSignature)
+		if i < 0 {
+			return comments
+		}
+		line = line[i+len(gitleaksAllowSignature):]
+		if strings.HasPrefix(line, "-") {
+			// gitleaks:allow-begin and gitleaks:allow-end markers
+			// only apply to the region they enclose
+			continue
+		}
+		var comment allowComment
+		line = parseAllowParams(line, &comment)
+		comments = append(comments, comment)
+	}
+}
+
+// parseAllowParams parses the key=value parameters at the start of s into
+// comment and returns the rest of s
+func parseAllowParams(s string, comment *allowComment) string {
+	for {
+		rest := strings.TrimLeft(s, " \t")
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return s
+		}
+		key := rest[:eq]
+		var value string
+		rest = rest[eq+1:]
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return s
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+
+		switch key {
+		case "rule":
+			comment.ruleIDs = strings.Split(value, ",")
+		case "until":
+			comment.until = value
+		case "reason":
+			comment.reason = value
+		default:
+			return s
+		}
+		s = rest
+	}
+}
+
+// allows reports whether the comment suppresses findings of ruleID on date now
+func (c allowComment) allows(ruleID string, now time.Time) bool {
+	if len(c.ruleIDs) != 0 {
+		found := false
+		for _, id := range c.ruleIDs {
+			if id == ruleID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.until != "" {
+		until, err := time.Parse(allowDateLayout, c.until)
+		if err != nil {
+			log.Warn().Msgf("ignoring %s comment with invalid date until=%s, expected YYYY-MM-DD", gitleaksAllowSignature, c.until)
+			return false
+		}
+		// the comment applies up to and including the until date
+		if now.After(until.AddDate(0, 0, 1)) {
+			return false
+		}
+	}
+	return true
+}
+
+// suppression returns the suppression of a finding of ruleID on a line,
+// or nil if no gitleaks:allow comment on the line applies to the rule
+func suppression(line string, ruleID string) *report.Suppression {
+	for _, comment := range parseAllowComments(line) {
+		if comment.allows(ruleID, time.Now()) {
+			return &report.Suppression{
+				Reason: comment.reason,
+				Until:  comment.until,
+			}
+		}
+	}
+	return nil
+}
+
+// allowRegion is a region enclosed by gitleaks:allow-begin and
+// gitleaks:allow-end markers. Depending on where it comes from, start and
+// end are byte offsets in a fragment or line numbers in a file. start is
+// inclusive and end is exclusive.
+type allowRegion struct {
+	start   int
+	end     int
+	comment allowComment
+}
+
+// allowMarker is a gitleaks:allow-begin or gitleaks:allow-end marker
+type allowMarker struct {
+	// start and end are the position of the line holding the marker
+	start int
+	end   int
+	begin bool
+
+	// comment holds the parameters of a gitleaks:allow-begin marker
+	comment allowComment
+}
+
+// findAllowMarkers returns the markers in line, positioned at start and end
+func findAllowMarkers(line string, start int, end int) []allowMarker {
+	var markers []allowMarker
+	for {
+		i := strings.Index(line, gitleaksAllowSignature+"-")
+		if i < 0 {
+			return markers
+		}
+		line = line[i:]
+		switch {
+		case strings.HasPrefix(line, gitleaksAllowBeginSignature):
+			marker := allowMarker{start: start, end: end, begin: true}
+			line = parseAllowParams(line[len(gitleaksAllowBeginSignature):], &marker.comment)
+			markers = append(markers, marker)
+		case strings.HasPrefix(line, gitleaksAllowEndSignature):
+			markers = append(markers, allowMarker{start: start, end: end})
+			line = line[len(gitleaksAllowEndSignature):]
+		default:
+			line = line[len(gitleaksAllowSignature)+1:]
+		}
+	}
+}
+
+// pairAllowMarkers turns ordered markers into regions spanning from the line
+// of a begin marker to the line of the next end marker. A begin marker
+// without an end marker applies up to limit. Nested begin markers and
+// stray end markers are ignored.
+func pairAllowMarkers(markers []allowMarker, limit int) []allowRegion {
+	var (
+		regions []allowRegion
+		open    *allowMarker
+	)
+	for i := range markers {
+		marker := markers[i]
+		switch {
+		case marker.begin && open == nil:
+			open = &markers[i]
+		case !marker.begin && open != nil:
+			regions = append(regions, allowRegion{
+				start:   open.start,
+				end:     marker.end,
+				comment: open.comment,
+			})
+			open = nil
+		}
+	}
+	if open != nil {
+		regions = append(regions, allowRegion{
+			start:   open.start,
+			end:     limit,
+			comment: open.comment,
+		})
+	}
+	return regions
+}
+
+// fragmentAllowRegions returns the regions of raw enclosed by markers as
+// byte offsets. open is the comment of a region left open by the content
+// preceding raw, e.g. the previous chunk of a file, which then applies from
+// the start of raw.
+func fragmentAllowRegions(raw string, open *allowComment) []allowRegion {
+	if open == nil && !strings.Contains(raw, gitleaksAllowSignature+"-") {
+		return nil
+	}
+	var markers []allowMarker
+	if open != nil {
+		markers = append(markers, allowMarker{begin: true, comment: *open})
+	}
+	for start := 0; start < len(raw); {
+		end := strings.IndexByte(raw[start:], '\n')
+		if end < 0 {
+			end = len(raw)
+		} else {
+			end += start + 1
+		}
+		markers = append(markers, findAllowMarkers(raw[start:end], start, end)...)
+		start = end
+	}
+	return pairAllowMarkers(markers, len(raw))
+}
+
+// openAllowRegion returns the comment of the region that begins before offset
+// and ends after it, or nil if there is none
+func openAllowRegion(regions []allowRegion, offset int) *allowComment {
+	for _, region := range regions {
+		if region.start < offset && region.end > offset {
+			return &region.comment
+		}
+	}
+	return nil
+}
+
+// regionSuppression returns the suppression of a finding of ruleID spanning
+// from start to end, or nil if it is not inside a region that applies to
+// the rule
+func regionSuppression(regions []allowRegion, start int, end int, ruleID string) *report.Suppression {
+	for _, region := range regions {
+		if start >= region.start && end <= region.end && region.comment.allows(ruleID, time.Now()) {
+			return &report.Suppression{
+				Reason: region.comment.reason,
+				Until:  region.comment.until,
+			}
+		}
+	}
+	return nil
+}
+
+// gitAllowRegions returns the regions enclosed by markers in the lines added
+// by the hunks of a file, as line numbers in the new version of the file, so
+// markers apply across hunks. Only the hunks of one commit are known, markers
+// added to the file by other commits do not apply.
+func gitAllowRegions(textFragments []*gitdiff.TextFragment) []allowRegion {
+	var markers []allowMarker
+	for _, textFragment := range textFragments {
+		if textFragment == nil {
+			break
+		}
+		raw := textFragment.Raw(gitdiff.OpAdd)
+		if !strings.Contains(raw, gitleaksAllowSignature+"-") {
+			continue
+		}
+		for i, line := range strings.Split(raw, "\n") {
+			lineNumber := int(textFragment.NewPosition) + i
+			markers = append(markers, findAllowMarkers(line, lineNumber, lineNumber+1)...)
+		}
+	}
+	return pairAllowMarkers(markers, math.MaxInt)
+}
@@ -0,0 +1,151 @@
+package detect
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/h2non/filetype/matchers"
+	"github.com/h2non/filetype/types"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+const (
+	// defaultMaxArchiveDepth is the default number of nested
+	// archives that are opened when ScanArchives is set
+	defaultMaxArchiveDepth = 5
+
+	// archiveSeparator separates the path of an archive from the
+	// path of an entry in that archive, e.g. dist/app.jar!/META-INF/config.properties
+	archiveSeparator = "!/"
+
+	// maxInMemoryZipSize is the size up to which nested zip archives are
+	// read into memory, whatever MaxTargetMegaBytes is, so a zip bomb
+	// cannot exhaust memory
+	maxInMemoryZipSize = 256 * 1024 * 1024
+)
+
+// isArchive returns true if the file type is an archive that can be opened
+func isArchive(t types.Type) bool {
+	switch t {
+	case matchers.TypeZip, matchers.TypeTar, matchers.TypeGz:
+		return true
+	}
+	return false
+}
+
+// detectArchive scans the entries of the zip, tar or gzip archive at path.
+// r is the archive itself and br is a buffered reader wrapping r which
+// must be used to read r sequentially. Entries are scanned with
+// detectReader and reported as path!/entry.
+func (d *Detector) detectArchive(ctx context.Context, path string, t types.Type, r io.Reader, br *bufio.Reader,
+	depth int, onFinding func(report.Finding) error) error {
+	switch t {
+	case matchers.TypeGz:
+		// gzip holds a single compressed file, the content is
+		// reported as the gzip file itself
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			log.Debug().Msgf("skipping %s, unable to open gzip: %s", path, err)
+			return nil
+		}
+		defer gz.Close()
+		return d.detectReader(ctx, path, gz, depth, onFinding)
+	case matchers.TypeTar:
+		tr := tar.NewReader(br)
+		for {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				log.Debug().Msgf("skipping rest of %s, unable to read tar: %s", path, err)
+				return nil
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			entryPath := path + archiveSeparator + hdr.Name
+			if d.tooLarge(hdr.Size) {
+				d.addSkippedFile(entryPath, hdr.Size)
+				continue
+			}
+			if err = d.detectReader(ctx, entryPath, tr, depth, onFinding); err != nil {
+				return err
+			}
+		}
+	case matchers.TypeZip:
+		limit := int64(maxInMemoryZipSize)
+		if d.tooLarge(limit) {
+			limit = int64(d.MaxTargetMegaBytes) * 1024 * 1024
+		}
+		zr, err := openZip(path, r, br, limit)
+		if err != nil {
+			log.Debug().Msgf("skipping %s, unable to open zip: %s", path, err)
+			return nil
+		}
+		for _, f := range zr.File {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			entryPath := path + archiveSeparator + f.Name
+			if d.tooLarge(int64(f.UncompressedSize64)) {
+				d.addSkippedFile(entryPath, int64(f.UncompressedSize64))
+				continue
+			}
+			if err = d.detectZipEntry(ctx, entryPath, f, depth, onFinding); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported archive", path)
+}
+
+// detectZipEntry scans a single file in a zip archive
+func (d *Detector) detectZipEntry(ctx context.Context, entryPath string, f *zip.File, depth int,
+	onFinding func(report.Finding) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		log.Debug().Msgf("skipping %s, unable to open zip entry: %s", entryPath, err)
+		return nil
+	}
+	defer rc.Close()
+	return d.detectReader(ctx, entryPath, rc, depth, onFinding)
+}
+
+// openZip opens a zip archive. Zip archives need random access, which files
+// on disk provide. Nested archives are read into memory, up to limit bytes.
+func openZip(path string, r io.Reader, br *bufio.Reader, limit int64) (*zip.Reader, error) {
+	if f, ok := r.(*os.File); ok {
+		fInfo, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return zip.NewReader(f, fInfo.Size())
+	}
+
+	b, err := io.ReadAll(io.LimitReader(br, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, limit)
+	}
+	return zip.NewReader(bytes.NewReader(b), int64(len(b)))
+}
@@ -0,0 +1,55 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// IsNew returns true if the finding is not present in the baseline. Line
+// numbers are deliberately ignored so that a baseline stays valid when
+// unrelated edits shift a finding up or down in a file.
+func IsNew(finding report.Finding, baseline []report.Finding) bool {
+	// Explicitly comparing each field is significantly faster than a
+	// reflection based comparison. Drawback is that this needs to be
+	// maintained if/when the Finding struct changes.
+	for _, b := range baseline {
+		if finding.RuleID == b.RuleID &&
+			finding.File == b.File &&
+			finding.Commit == b.Commit &&
+			finding.Secret == b.Secret &&
+			finding.Match == b.Match &&
+			finding.StartColumn == b.StartColumn &&
+			finding.EndColumn == b.EndColumn &&
+			finding.Author == b.Author &&
+			finding.Email == b.Email &&
+			finding.Date == b.Date &&
+			finding.Message == b.Message {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadBaseline reads a gitleaks json report, as written by report.Write,
+// and returns the findings it contains. Reports of incomplete scans and
+// reports with suppressed findings are accepted as well.
+func LoadBaseline(baselinePath string) ([]report.Finding, error) {
+	bytes, err := os.ReadFile(baselinePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", baselinePath, err)
+	}
+
+	var previousFindings []report.Finding
+	if err = json.Unmarshal(bytes, &previousFindings); err == nil {
+		return previousFindings, nil
+	}
+	var jsonReport report.JsonReport
+	if reportErr := json.Unmarshal(bytes, &jsonReport); reportErr != nil || jsonReport.Findings == nil {
+		return nil, fmt.Errorf("the format of the file %s is not supported: %w", baselinePath, err)
+	}
+
+	return jsonReport.Findings, nil
+}
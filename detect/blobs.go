@@ -0,0 +1,270 @@
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/semgroup"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+const (
+	// binaryCheckSize is the number of bytes at the start of a blob that
+	// are checked for a NUL byte to tell binary blobs apart, like git does
+	binaryCheckSize = 8000
+
+	// blobBatchSize is the number of unique blobs read and scanned before
+	// the findings of the commits that changed them are handed over
+	blobBatchSize = 1000
+)
+
+// DetectGitBlobs scans the history of a git repository like DetectGit, but
+// reads it from repo as the blobs of the files changed by each commit. Each
+// unique blob of a batch of commits is scanned once per path it is found at,
+// however many commits of the batch share it. The scan stops early and
+// returns ctx.Err() if ctx is cancelled.
+func (d *Detector) DetectGitBlobs(ctx context.Context, repo git.Repository) ([]report.Finding, error) {
+	err := d.DetectGitBlobsStream(ctx, repo, d.appendFinding)
+	return d.findings, err
+}
+
+// DetectGitBlobsStream scans a git history like DetectGitBlobs but hands each
+// finding to onFinding instead of accumulating findings in memory. Commits are
+// read in batches, and the findings of a batch are handed over once the blobs
+// it changed are scanned. A secret is reported for each commit whose version of
+// a file contains it while the previous version of the file did not. Blobs
+// larger than MaxTargetMegaBytes are skipped, blobs larger than a chunk are
+// scanned in chunks as they are read like files are. onFinding is never called
+// concurrently. If onFinding returns an error the scan is stopped and that
+// error is returned.
+func (d *Detector) DetectGitBlobsStream(ctx context.Context, repo git.Repository, onFinding func(report.Finding) error) error {
+	rules := make(map[string]*config.Rule)
+	for _, rule := range d.Config.Rules {
+		rules[rule.RuleID] = rule
+	}
+
+	chunkSize, chunkOverlap := d.chunkSizes()
+	var (
+		// commits are the commits of the current batch, blobs are the
+		// blobs they changed along with the paths they are scanned at.
+		// queued holds the blobs of the batch by blobKey and found the
+		// findings of those which had any. Nothing is kept once the
+		// commits of the batch are reported, so memory stays bounded
+		// by the size of a batch. A blob shared by commits of several
+		// batches is scanned again in each of them.
+		commits    []git.Commit
+		blobs      []string
+		paths      = make(map[string][]string)
+		queued     = make(map[string]bool)
+		foundMutex sync.Mutex
+		found      = make(map[string][]report.Finding)
+	)
+	queue := func(blob string, path string) {
+		if blob == "" || queued[blobKey(blob, path)] {
+			return
+		}
+		queued[blobKey(blob, path)] = true
+		if _, ok := paths[blob]; !ok {
+			blobs = append(blobs, blob)
+		}
+		paths[blob] = append(paths[blob], path)
+	}
+	record := func(blob string, path string, findings []report.Finding) {
+		if len(findings) == 0 {
+			return
+		}
+		foundMutex.Lock()
+		found[blobKey(blob, path)] = findings
+		foundMutex.Unlock()
+	}
+
+	// scanBatch scans the blobs of the current batch once for each path
+	// they are found at, then reports the secrets each commit introduced
+	scanBatch := func() error {
+		log.Debug().Msgf("scanning %d unique blobs changed by %d commits", len(blobs), len(commits))
+		s := semgroup.NewGroup(ctx, d.workers())
+		err := repo.Blobs(ctx, blobs, d.maxTargetBytes(), func(blob string, size int64, content io.Reader) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			blobPaths := paths[blob]
+			if size > int64(chunkSize) {
+				// the content can only be read until the callback
+				// returns, so large blobs are scanned right away
+				return d.scanBlobChunks(ctx, content, blobPaths, chunkOverlap, func(path string, findings []report.Finding) {
+					record(blob, path, findings)
+				})
+			}
+			raw, err := io.ReadAll(content)
+			if err != nil {
+				return err
+			}
+			if isBinary(raw) {
+				return nil
+			}
+			s.Go(func() error {
+				content := string(raw)
+				singleLine := !strings.Contains(content, "\n")
+				for _, path := range blobPaths {
+					var findings []report.Finding
+					for _, finding := range d.detectFragment(Fragment{Raw: content, FilePath: path}) {
+						findings = append(findings, adjustChunkFinding(finding, singleLine, 0, 0))
+					}
+					record(blob, path, findings)
+				}
+				return nil
+			})
+			return nil
+		})
+		if waitErr := s.Wait(); err == nil {
+			err = waitErr
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, commit := range commits {
+			if err := d.reportCommitBlobs(ctx, commit, found, rules, onFinding); err != nil {
+				return err
+			}
+		}
+		commits, blobs = nil, nil
+		paths, queued, found = make(map[string][]string), make(map[string]bool), make(map[string][]report.Finding)
+		return nil
+	}
+
+	err := repo.Commits(ctx, func(commit git.Commit) error {
+		if d.Config.Allowlist.CommitAllowed(commit.SHA) {
+			return nil
+		}
+		d.addCommit(commit.SHA)
+		for _, change := range commit.Changes {
+			queue(change.NewBlob, change.Path)
+			queue(change.OldBlob, change.OldPath)
+		}
+		commits = append(commits, commit)
+		if len(blobs) < blobBatchSize {
+			return nil
+		}
+		return scanBatch()
+	})
+	if err == nil && len(commits) != 0 {
+		err = scanBatch()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	log.Debug().Msgf("%d commits scanned", len(d.commitMap))
+	d.logIgnored()
+	return nil
+}
+
+// scanBlobChunks scans the content of a blob larger than a chunk in chunks as
+// it is read, once for each of paths, and hands the findings at each path to
+// onFindings. Binary blobs are skipped.
+func (d *Detector) scanBlobChunks(ctx context.Context, content io.Reader, paths []string, chunkOverlap int,
+	onFindings func(path string, findings []report.Finding)) error {
+	bufferSize := chunkOverlap
+	if bufferSize < binaryCheckSize {
+		bufferSize = binaryCheckSize
+	}
+	r := bufio.NewReaderSize(content, bufferSize)
+	header, err := r.Peek(binaryCheckSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if isBinary(header) {
+		return nil
+	}
+
+	// composite rules are resolved once all chunks are scanned
+	byPath := make(map[string][]report.Finding)
+	err = d.scanChunks(ctx, paths, r, func(findings []report.Finding) error {
+		for _, finding := range findings {
+			byPath[finding.File] = append(byPath[finding.File], finding)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for path, findings := range byPath {
+		onFindings(path, d.resolveFindings(findings))
+	}
+	return nil
+}
+
+// reportCommitBlobs hands the findings in the blobs changed by commit that
+// are not in the previous versions of the files to onFinding. found holds
+// the findings of the scanned blobs by blobKey.
+func (d *Detector) reportCommitBlobs(ctx context.Context, commit git.Commit, found map[string][]report.Finding,
+	rules map[string]*config.Rule, onFinding func(report.Finding) error) error {
+	for _, change := range commit.Changes {
+		findings := found[blobKey(change.NewBlob, change.Path)]
+		if len(findings) == 0 {
+			continue
+		}
+		previous := make(map[string]bool)
+		for _, finding := range found[blobKey(change.OldBlob, change.OldPath)] {
+			previous[finding.RuleID+":"+finding.Secret] = true
+		}
+		for _, finding := range findings {
+			if previous[finding.RuleID+":"+finding.Secret] {
+				continue
+			}
+			if rule, ok := rules[finding.RuleID]; ok && rule.Allowlist.CommitAllowed(commit.SHA) {
+				continue
+			}
+			finding = augmentCommitFinding(finding, commit)
+			if d.isIgnored(finding) {
+				continue
+			}
+			if err := d.addFinding(ctx, finding, onFinding); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// augmentCommitFinding adds the information of the commit a finding was
+// introduced by to the finding
+func augmentCommitFinding(finding report.Finding, commit git.Commit) report.Finding {
+	finding.Commit = commit.SHA
+	finding.Message = commit.Message
+	finding.Author = commit.Author
+	finding.Email = commit.Email
+	finding.Date = commit.Date.UTC().Format(time.RFC3339)
+	finding.Fingerprint = gitFingerprint(finding)
+	return finding
+}
+
+// blobKey identifies a blob scanned at a path
+func blobKey(blob string, path string) string {
+	return blob + ":" + path
+}
+
+// isBinary reports whether content looks like the content of a binary file
+func isBinary(content []byte) bool {
+	if len(content) > binaryCheckSize {
+		content = content[:binaryCheckSize]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,118 @@
+package detect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+)
+
+// scanCache is the state of earlier scans of a repo, see UseScanCache
+type scanCache struct {
+	// Repo is the absolute path of the repo
+	Repo string
+
+	// ConfigHash identifies the config and detector options the
+	// commits were scanned with
+	ConfigHash string
+
+	// Commits are the commits whose history has been scanned, i.e. the
+	// commits the refs of the repo pointed to at the end of the last scan
+	Commits []string
+
+	// path is the file the cache is stored in
+	path string
+
+	// tips are the commits the refs of the repo point to at the start of
+	// the current scan. They replace Commits once the scan completes.
+	tips []string
+}
+
+// UseScanCache makes DetectGit only scan the commits of the repo at source
+// that were not scanned by earlier runs. The scanned commits are recorded in
+// a file in cacheDir named after the repo, only by scans that completed
+// without reporting findings so leaks are reported until they are fixed,
+// ignored or added to the baseline. The cache is invalidated when the
+// config or a detector option that changes the findings of a scan differs
+// from the earlier runs, so the detector options must be set before calling
+// UseScanCache. The cache only applies to DetectType scans without log options
+// or a log range.
+func (d *Detector) UseScanCache(cacheDir string, source string) error {
+	if cacheDir == "" {
+		return nil
+	}
+	repo, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	repoHash := sha256.Sum256([]byte(repo))
+	configHash := sha256.Sum256([]byte(fmt.Sprintf("%s max-decode-depth=%d group-hunks=%v",
+		d.Config.Hash(), d.MaxDecodeDepth, d.GroupHunks)))
+	cache := &scanCache{
+		Repo:       repo,
+		ConfigHash: hex.EncodeToString(configHash[:]),
+		path:       filepath.Join(cacheDir, hex.EncodeToString(repoHash[:8])+".json"),
+	}
+
+	b, err := os.ReadFile(cache.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var previous scanCache
+		if err = json.Unmarshal(b, &previous); err != nil {
+			log.Warn().Msgf("ignoring invalid scan cache %s: %s", cache.path, err)
+		} else if previous.Repo == cache.Repo && previous.ConfigHash == cache.ConfigHash {
+			cache.Commits = previous.Commits
+		} else {
+			log.Info().Msg("config changed since the last scan, scanning the full history")
+		}
+	}
+	d.scanCache = cache
+	return nil
+}
+
+// gitLog logs the commits of the repo at source that are not reachable
+// from the commits recorded in the cache. It returns nil if the repo has no
+// commits.
+func (c *scanCache) gitLog(ctx context.Context, source string, classify git.StderrClassifier) (*git.PatchCmd, error) {
+	tips, err := git.RefTips(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	c.tips = tips
+	if len(tips) == 0 {
+		return nil, nil
+	}
+	if len(c.Commits) != 0 {
+		log.Info().Msg("skipping commits scanned by earlier runs")
+	}
+	return git.GitLogRevisions(ctx, source, tips, c.Commits, classify)
+}
+
+// save records that the history of the commits logged by gitLog has been
+// scanned
+func (c *scanCache) save() error {
+	c.Commits = c.tips
+	b, err := json.MarshalIndent(c, "", " ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so an interrupted write does not
+	// leave a truncated cache behind
+	tmpPath := c.path + ".tmp"
+	if err = os.WriteFile(tmpPath, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, c.path)
+}
@@ -0,0 +1,99 @@
+package detect
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+func TestDetectGitScanCache(t *testing.T) {
+	err := moveDotGit("dotGit", ".git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := moveDotGit(".git", "dotGit"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	viper.AddConfigPath(configPath)
+	viper.SetConfigName("simple")
+	viper.SetConfigType("toml")
+	if err = viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	var vc config.ViperConfig
+	if err = viper.Unmarshal(&vc); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := vc.Translate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	source := filepath.Join(repoBasePath, "small")
+	scan := func(maxDecodeDepth int, ignore map[string]bool) (*Detector, []report.Finding) {
+		detector := NewDetector(cfg)
+		detector.MaxDecodeDepth = maxDecodeDepth
+		detector.gitleaksIgnore = ignore
+		if err := detector.UseScanCache(cacheDir, source); err != nil {
+			t.Fatal(err)
+		}
+		findings, err := detector.DetectGit(context.Background(), source, "", DetectType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return detector, findings
+	}
+
+	// the first scan scans the full history
+	detector, findings := scan(0, nil)
+	assert.Len(t, findings, 2)
+
+	// the cache is not updated while there are leaks
+	_, findings = scan(0, nil)
+	assert.Len(t, findings, 2)
+	ignore := make(map[string]bool)
+	for _, f := range findings {
+		ignore[f.Fingerprint] = true
+	}
+
+	// once the leaks are ignored the scanned commits are recorded
+	_, findings = scan(0, ignore)
+	assert.Len(t, findings, 0)
+	_, findings = scan(0, nil)
+	assert.Len(t, findings, 0)
+
+	// only the commits of the foo branch have not been scanned
+	cache := detector.scanCache
+	cache.Commits = []string{"2e1db472eeba53f06c4026ae4566ea022e36598e"}
+	b, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(cache.path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, findings = scan(0, nil)
+	assert.Len(t, findings, 1)
+	_, findings = scan(0, nil)
+	assert.Len(t, findings, 1)
+
+	// changing an option that changes the findings invalidates the cache
+	_, findings = scan(1, ignore)
+	assert.Len(t, findings, 0)
+	_, findings = scan(1, nil)
+	assert.Len(t, findings, 0)
+	_, findings = scan(0, nil)
+	assert.Len(t, findings, 2)
+}
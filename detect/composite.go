@@ -0,0 +1,87 @@
+package detect
+
+import (
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// applyRequired drops the findings of composite rules whose required rules
+// did not match nearby and attaches the nearest match of each required rule
+// to the findings that are kept. Findings of rules with SkipReport set are
+// dropped once they have been used.
+func (d *Detector) applyRequired(findings []report.Finding) []report.Finding {
+	rules := make(map[string]*config.Rule)
+	for _, rule := range d.Config.Rules {
+		rules[rule.RuleID] = rule
+	}
+
+	var retFindings []report.Finding
+	for _, finding := range findings {
+		rule, ok := rules[finding.RuleID]
+		if !ok {
+			retFindings = append(retFindings, finding)
+			continue
+		}
+		if rule.SkipReport {
+			continue
+		}
+		if len(rule.Required) == 0 {
+			retFindings = append(retFindings, finding)
+			continue
+		}
+
+		var requiredFindings []report.RequiredFinding
+		for _, req := range rule.Required {
+			nearest := -1
+			for i, candidate := range findings {
+				if candidate.RuleID != req.RuleID {
+					continue
+				}
+				distance := lineDistance(finding, candidate)
+				if req.WithinLines != nil && distance > *req.WithinLines {
+					continue
+				}
+				if nearest < 0 || distance < lineDistance(finding, findings[nearest]) {
+					nearest = i
+				}
+			}
+			if nearest < 0 {
+				break
+			}
+			requiredFindings = append(requiredFindings, report.RequiredFinding{
+				RuleID: findings[nearest].RuleID,
+				Match:  findings[nearest].Match,
+				Secret: findings[nearest].Secret,
+			})
+		}
+		if len(requiredFindings) != len(rule.Required) {
+			// a required rule did not match
+			continue
+		}
+		finding.RequiredFindings = requiredFindings
+		retFindings = append(retFindings, finding)
+	}
+	return retFindings
+}
+
+// hasComposite returns true if a rule of the config requires other rules or
+// is only used as the requirement of other rules
+func (d *Detector) hasComposite() bool {
+	for _, rule := range d.Config.Rules {
+		if len(rule.Required) != 0 || rule.SkipReport {
+			return true
+		}
+	}
+	return false
+}
+
+// lineDistance returns the number of lines between two findings
+func lineDistance(a report.Finding, b report.Finding) int {
+	if b.StartLine > a.EndLine {
+		return b.StartLine - a.EndLine
+	}
+	if a.StartLine > b.EndLine {
+		return a.StartLine - b.EndLine
+	}
+	return 0
+}
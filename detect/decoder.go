@@ -0,0 +1,127 @@
+package detect
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// encodedBlobRegex matches candidate base64, base64url and hex encoded blobs.
+// Short blobs are ignored since they rarely decode to anything meaningful.
+var encodedBlobRegex = regexp.MustCompile(`[A-Za-z0-9+/_\-]{16,}={0,2}`)
+
+// decodeBlob decodes an encoded blob and returns the decoded text along with
+// the name of the encoding. Hex is tried before base64 as every hex string is
+// also valid base64. Blobs that do not decode to printable text are skipped.
+func decodeBlob(blob string) (string, string, bool) {
+	if len(blob)%2 == 0 && isHex(blob) {
+		if decoded, err := hex.DecodeString(blob); err == nil && isPrintable(decoded) {
+			return string(decoded), "hex", true
+		}
+	}
+
+	trimmed := strings.TrimRight(blob, "=")
+	std := strings.ContainsAny(trimmed, "+/")
+	url := strings.ContainsAny(trimmed, "-_")
+	if std && url {
+		return "", "", false
+	}
+	encoding, name := base64.RawStdEncoding, "base64"
+	if url {
+		encoding, name = base64.RawURLEncoding, "base64url"
+	}
+	decoded, err := encoding.DecodeString(trimmed)
+	if err != nil || !isPrintable(decoded) {
+		return "", "", false
+	}
+	return string(decoded), name, true
+}
+
+// detectDecoded decodes the encoded blobs in fragment and scans the decoded
+// text. encodings lists the decodings that produced fragment, the recursion
+// stops once it reaches MaxDecodeDepth. Findings in decoded text are reported
+// at the location of the encoded blob in fragment.
+func (d *Detector) detectDecoded(fragment Fragment, encodings []string) []report.Finding {
+	var findings []report.Finding
+	if len(encodings) >= d.MaxDecodeDepth {
+		return findings
+	}
+
+	for _, blobIndex := range encodedBlobRegex.FindAllStringIndex(fragment.Raw, -1) {
+		decoded, encoding, ok := decodeBlob(fragment.Raw[blobIndex[0]:blobIndex[1]])
+		if !ok {
+			continue
+		}
+		loc := location(fragment, blobIndex)
+
+		decodedFragment := Fragment{
+			Raw:       decoded,
+			FilePath:  fragment.FilePath,
+			CommitSHA: fragment.CommitSHA,
+		}
+		decodedEncodings := append(append([]string(nil), encodings...), encoding)
+		for _, finding := range d.detect(decodedFragment, decodedEncodings) {
+			finding.StartLine = loc.startLine
+			finding.EndLine = loc.endLine
+			finding.StartColumn = loc.startColumn
+			finding.EndColumn = loc.endColumn
+			if finding.Suppression == nil {
+				finding.Suppression = suppression(fragment.Raw[loc.startLineIndex:loc.endLineIndex], finding.RuleID)
+			}
+			if finding.Suppression == nil {
+				finding.Suppression = regionSuppression(fragment.allowRegions, blobIndex[0], blobIndex[1], finding.RuleID)
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// decodedTags returns the tags of a finding in text decoded with encodings,
+// e.g. [decoded:base64 decoded:hex decode-depth:2]
+func decodedTags(tags []string, encodings []string) []string {
+	decodedTags := append([]string(nil), tags...)
+	for _, encoding := range encodings {
+		decodedTags = append(decodedTags, "decoded:"+encoding)
+	}
+	return append(decodedTags, fmt.Sprintf("decode-depth:%d", len(encodings)))
+}
+
+// isDecoded returns true if the finding was found in decoded text
+func isDecoded(finding report.Finding) bool {
+	for _, tag := range finding.Tags {
+		if strings.HasPrefix(tag, "decoded:") {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPrintable reports whether b is utf-8 text without control characters
+// other than whitespace
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
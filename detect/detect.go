@@ -1,21 +1,23 @@
 package detect
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/zricethezav/gitleaks/v8/config"
 	"github.com/zricethezav/gitleaks/v8/detect/git"
 	"github.com/zricethezav/gitleaks/v8/report"
+	"github.com/zricethezav/gitleaks/v8/verify"
 
 	"github.com/fatih/semgroup"
-	"github.com/gitleaks/go-gitdiff/gitdiff"
-	"github.com/h2non/filetype"
 	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -48,6 +50,66 @@ type Detector struct {
 	// verbose is a flag to print findings
 	Verbose bool
 
+	// Threads is the number of workers used to read files and scan
+	// fragments concurrently. Values below 1 are treated as 1.
+	// NewDetector defaults this to runtime.NumCPU().
+	Threads int
+
+	// MaxTargetMegaBytes is the size above which files are skipped when
+	// scanning files with DetectFiles. 0 means there is no limit.
+	MaxTargetMegaBytes int
+
+	// ScanArchives enables scanning the entries of zip, tar and gzip
+	// archives with DetectFiles. Findings in archives are reported
+	// with a nested path like dist/app.jar!/META-INF/config.properties
+	ScanArchives bool
+
+	// MaxArchiveDepth is the number of nested archives that are opened
+	// when ScanArchives is set. NewDetector defaults this to 5.
+	MaxArchiveDepth int
+
+	// GroupHunks makes DetectGit scan all lines added to a file by a
+	// commit as one fragment instead of scanning each diff hunk on its
+	// own, so multi-line secrets split across hunks are detected.
+	GroupHunks bool
+
+	// CheckHead makes DetectGit record on each finding whether its secret
+	// is still in the tree of the default branch, origin/HEAD or HEAD if
+	// there is none, and, if not, the commit that removed it. The commits
+	// that removed secrets are looked up together once the history has
+	// been scanned, so findings of removed secrets are held in memory and
+	// reported last. It only applies to DetectType scans.
+	CheckHead bool
+
+	// LogRange selects the commits DetectGit scans by refs, dates and
+	// count instead of by log options, it cannot be combined with them.
+	// It only applies to DetectType scans. Its refs are not looked up
+	// before git log is run, check them with Validate for a clear error.
+	LogRange git.LogRange
+
+	// GitStderrClassifier tells which lines git writes to stderr are
+	// benign warnings. Other lines make DetectGit return an error.
+	// git.DefaultStderrClassifier is used if nil.
+	GitStderrClassifier git.StderrClassifier
+
+	// MaxDecodeDepth is the number of times base64, base64url and hex
+	// encoded blobs are decoded and scanned again, e.g. 2 also finds
+	// secrets that were hex encoded and then base64 encoded.
+	// 0 disables decoding.
+	MaxDecodeDepth int
+
+	// Verifiers check whether the secrets found by a rule are live, keyed
+	// by rule ID. Findings of rules without a verifier are not checked.
+	// nil disables verification.
+	Verifiers map[string]verify.Verifier
+
+	// OnSuppressed is called with each finding suppressed by a
+	// gitleaks:allow comment, so waived findings can be audited. It is
+	// never called concurrently. If it returns an error the scan is
+	// stopped and that error is returned. Suppressed findings are only
+	// counted if nil.
+	OnSuppressed func(report.Finding) error
+
 	// commitMap is used to keep track of commits that have been scanned.
 	// This is only used for logging purposes and git scans.
 	commitMap map[string]bool
@@ -64,6 +126,53 @@ type Detector struct {
 	// prefilter is a ahocorasick struct used for doing efficient string
 	// matching given a set of words (keywords from the rules in the config)
 	prefilter ahocorasick.AhoCorasick
+
+	// baseline is a list of findings from a previous scan. Findings that
+	// are present in the baseline are not reported again.
+	baseline []report.Finding
+
+	// gitleaksIgnore is a set of finding fingerprints loaded from a
+	// .gitleaksignore file. Findings with a matching fingerprint are dropped.
+	gitleaksIgnore map[string]bool
+
+	// suppressedCount is the number of findings suppressed by
+	// gitleaks:allow comments.
+	suppressedCount int
+
+	// ignoredCount is the number of findings dropped because their
+	// fingerprint is listed in gitleaksIgnore.
+	ignoredCount int
+
+	// skippedFiles is the number of files not scanned by DetectFiles
+	// because they are larger than MaxTargetMegaBytes.
+	skippedFiles int
+
+	// verified caches the status of verified secrets, keyed by
+	// rule ID and secret.
+	verified sync.Map
+
+	// headRef is the default branch findings are checked against, it is
+	// resolved once per detector. headLookups holds a *headLookup for
+	// each secret checked against it.
+	headRefOnce sync.Once
+	headRef     string
+	headRefErr  error
+	headLookups sync.Map
+
+	// absentFindings are the findings whose secret is no longer on the
+	// default branch, held back until the commits that removed them have
+	// been looked up. It is guarded by findingMutex.
+	absentFindings []absentFinding
+
+	// scanCache holds the commits scanned by earlier runs, see
+	// UseScanCache. nil if the cache is not used.
+	scanCache *scanCache
+
+	// chunkSize and chunkOverlap control how DetectFiles splits files into
+	// fragments, see detectFile. NewDetector sets them to defaultChunkSize
+	// and defaultChunkOverlap.
+	chunkSize    int
+	chunkOverlap int
 }
 
 // Fragment contains the data to be scanned
@@ -84,6 +193,14 @@ type Fragment struct {
 	// keywords is a map of all the keywords contain within the contents
 	// of this fragment
 	keywords map[string]bool
+
+	// allowRegions are the regions of the raw content enclosed by
+	// gitleaks:allow-begin and gitleaks:allow-end markers
+	allowRegions []allowRegion
+
+	// openAllowRegion is the comment of a region left open by the
+	// previous chunk of the file the raw content was read from
+	openAllowRegion *allowComment
 }
 
 // NewDetector creates a new detector with the given config
@@ -96,11 +213,15 @@ func NewDetector(cfg config.Config) *Detector {
 	})
 
 	return &Detector{
-		commitMap:    make(map[string]bool),
-		findingMutex: &sync.Mutex{},
-		findings:     make([]report.Finding, 0),
-		Config:       cfg,
-		prefilter:    builder.Build(cfg.Keywords),
+		commitMap:       make(map[string]bool),
+		findingMutex:    &sync.Mutex{},
+		findings:        make([]report.Finding, 0),
+		Config:          cfg,
+		Threads:         runtime.NumCPU(),
+		MaxArchiveDepth: defaultMaxArchiveDepth,
+		chunkSize:       defaultChunkSize,
+		chunkOverlap:    defaultChunkOverlap,
+		prefilter:       builder.Build(cfg.Keywords),
 	}
 }
 
@@ -123,6 +244,36 @@ func NewDetectorDefaultConfig() (*Detector, error) {
 	return NewDetector(cfg), nil
 }
 
+// AddBaseline loads the findings of a previous gitleaks json report.
+// Findings already present in the baseline will not be reported.
+func (d *Detector) AddBaseline(baselinePath string) error {
+	if baselinePath == "" {
+		return nil
+	}
+	baseline, err := LoadBaseline(baselinePath)
+	if err != nil {
+		return err
+	}
+	d.baseline = baseline
+	return nil
+}
+
+// AddGitleaksIgnore loads the finding fingerprints listed in a .gitleaksignore
+// file. If gitleaksIgnorePath is a directory, the .gitleaksignore file in that
+// directory is used.
+func (d *Detector) AddGitleaksIgnore(gitleaksIgnorePath string) error {
+	if fileInfo, err := os.Stat(gitleaksIgnorePath); err == nil && fileInfo.IsDir() {
+		gitleaksIgnorePath = filepath.Join(gitleaksIgnorePath, gitleaksIgnoreFileName)
+	}
+	fingerprints, err := loadGitleaksIgnore(gitleaksIgnorePath)
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("loaded %d fingerprints from %s", len(fingerprints), gitleaksIgnorePath)
+	d.gitleaksIgnore = fingerprints
+	return nil
+}
+
 // DetectBytes scans the given bytes and returns a list of findings
 func (d *Detector) DetectBytes(content []byte) []report.Finding {
 	return d.DetectString(string(content))
@@ -135,6 +286,27 @@ func (d *Detector) DetectString(content string) []report.Finding {
 	})
 }
 
+// DetectReaderStream scans the content read from r as the content of a file
+// at path so path based rules and allowlists apply. The content is read and
+// scanned in overlapping chunks like DetectFiles so memory stays bounded
+// however much content there is, and lines are counted from 1. Each finding is
+// handed to onFinding unless it is present in the baseline or listed in
+// .gitleaksignore. If onFinding returns an error the scan is stopped and that
+// error is returned. The scan stops early and returns ctx.Err() if ctx is
+// cancelled.
+func (d *Detector) DetectReaderStream(ctx context.Context, r io.Reader, path string,
+	onFinding func(report.Finding) error) error {
+	_, chunkOverlap := d.chunkSizes()
+	err := d.detectChunks(ctx, path, bufio.NewReaderSize(r, chunkOverlap), func(finding report.Finding) error {
+		if d.isIgnored(finding) {
+			return nil
+		}
+		return d.addFinding(ctx, finding, onFinding)
+	})
+	d.logIgnored()
+	return err
+}
+
 // detectRule scans the given fragment for the given rule and returns a list of findings
 func (d *Detector) detectRule(fragment Fragment, rule *config.Rule) []report.Finding {
 	var findings []report.Finding
@@ -155,6 +327,7 @@ func (d *Detector) detectRule(fragment Fragment, rule *config.Rule) []report.Fin
 				Match:       fmt.Sprintf("file detected: %s", fragment.FilePath),
 				Tags:        rule.Tags,
 			}
+			finding.Fingerprint = fingerprint(finding)
 			return append(findings, finding)
 		}
 	} else if rule.Path != nil {
@@ -195,11 +368,6 @@ func (d *Detector) detectRule(fragment Fragment, rule *config.Rule) []report.Fin
 			Tags:        rule.Tags,
 		}
 
-		if strings.Contains(fragment.Raw[loc.startLineIndex:loc.endLineIndex],
-			gitleaksAllowSignature) {
-			continue
-		}
-
 		// check if the secret is in the allowlist
 		if rule.Allowlist.RegexAllowed(finding.Secret) ||
 			d.Config.Allowlist.RegexAllowed(finding.Secret) {
@@ -245,42 +413,87 @@ func (d *Detector) detectRule(fragment Fragment, rule *config.Rule) []report.Fin
 			}
 		}
 
+		// check if an inline gitleaks:allow comment suppresses the rule
+		finding.Suppression = suppression(fragment.Raw[loc.startLineIndex:loc.endLineIndex], rule.RuleID)
+		if finding.Suppression == nil {
+			finding.Suppression = regionSuppression(fragment.allowRegions, matchIndex[0], matchIndex[1], rule.RuleID)
+		}
+
+		finding.Fingerprint = fingerprint(finding)
 		findings = append(findings, finding)
 	}
 	return findings
 }
 
-// GitScan accepts a *gitdiff.File channel which contents a git history generated from
-// the output of `git log -p ...`. startGitScan will look at each file (patch) in the history
+// DetectGit accepts a *gitdiff.File channel which contents a git history generated from
+// the output of `git log -p ...`. DetectGit will look at each file (patch) in the history
 // and determine if the patch contains any findings.
-func (d *Detector) DetectGit(source string, logOpts string, gitScanType GitScanType) ([]report.Finding, error) {
+// The scan stops early and returns ctx.Err() if ctx is cancelled.
+func (d *Detector) DetectGit(ctx context.Context, source string, logOpts string, gitScanType GitScanType) ([]report.Finding, error) {
+	err := d.DetectGitStream(ctx, source, logOpts, gitScanType, d.appendFinding)
+	return d.findings, err
+}
+
+// DetectGitStream scans a git history like DetectGit but hands each finding to
+// onFinding as soon as it is found instead of accumulating findings in memory.
+// onFinding is never called concurrently. If onFinding returns an error the scan
+// is stopped and that error is returned.
+func (d *Detector) DetectGitStream(ctx context.Context, source string, logOpts string, gitScanType GitScanType,
+	onFinding func(report.Finding) error) error {
 	var (
-		gitdiffFiles <-chan *gitdiff.File
-		err          error
+		patches *git.PatchCmd
+		err     error
 	)
 	switch gitScanType {
 	case DetectType:
-		gitdiffFiles, err = git.GitLog(source, logOpts)
+		switch {
+		case !d.LogRange.IsZero() && logOpts != "":
+			return fmt.Errorf("log options cannot be combined with a log range")
+		case d.scanCache != nil && logOpts == "" && d.LogRange.IsZero():
+			patches, err = d.scanCache.gitLog(ctx, source, d.GitStderrClassifier)
+		default:
+			if d.scanCache != nil {
+				log.Warn().Msg("the scan cache is not used when log options or a log range are set")
+			}
+			if d.LogRange.IsZero() {
+				patches, err = git.GitLog(ctx, source, logOpts, d.GitStderrClassifier)
+			} else {
+				patches, err = git.GitLogRange(ctx, source, d.LogRange, d.GitStderrClassifier)
+			}
+		}
 		if err != nil {
-			return d.findings, err
+			return err
 		}
 	case ProtectType:
-		gitdiffFiles, err = git.GitDiff(source, false)
+		patches, err = git.GitDiff(ctx, source, false, d.GitStderrClassifier)
 		if err != nil {
-			return d.findings, err
+			return err
 		}
 	case ProtectStagedType:
-		gitdiffFiles, err = git.GitDiff(source, true)
+		patches, err = git.GitDiff(ctx, source, true, d.GitStderrClassifier)
 		if err != nil {
-			return d.findings, err
+			return err
 		}
 	}
+	if patches == nil {
+		log.Debug().Msg("nothing to scan in a repo without commits")
+		return nil
+	}
 
-	s := semgroup.NewGroup(context.Background(), 4)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream := newFindingStream(onFinding, cancel)
+	s := semgroup.NewGroup(ctx, d.workers())
 
-	for gitdiffFile := range gitdiffFiles {
+	for gitdiffFile := range patches.Files() {
 		gitdiffFile := gitdiffFile
 
+		// keep draining the channel so the git process can finish,
+		// but don't scan anything once the scan has been stopped
+		if ctx.Err() != nil {
+			continue
+		}
+
 		// skip binary files
 		if gitdiffFile.IsBinary || gitdiffFile.IsDelete {
 			continue
@@ -297,36 +510,67 @@ func (d *Detector) DetectGit(source string, logOpts string, gitScanType GitScanT
 		d.addCommit(commitSHA)
 
 		s.Go(func() error {
-			for _, textFragment := range gitdiffFile.TextFragments {
-				if textFragment == nil {
+			return d.detectGitFile(gitdiffFile, commitSHA, func(finding report.Finding) error {
+				if d.isIgnored(finding) {
 					return nil
 				}
-
-				fragment := Fragment{
-					Raw:       textFragment.Raw(gitdiff.OpAdd),
-					CommitSHA: commitSHA,
-					FilePath:  gitdiffFile.NewName,
-				}
-
-				for _, finding := range d.Detect(fragment) {
-					d.addFinding(augmentGitFinding(finding, textFragment, gitdiffFile))
+				if d.CheckHead && gitScanType == DetectType && finding.Suppression == nil {
+					d.checkHead(ctx, source, &finding)
+					return d.addFinding(ctx, finding, d.holdAbsent(finding.Secret, stream.send))
 				}
-			}
-			return nil
+				return d.addFinding(ctx, finding, stream.send)
+			})
 		})
 	}
 
-	if err := s.Wait(); err != nil {
-		return d.findings, err
+	err = stream.wait(ctx, s)
+	if err == nil {
+		// git failing means part of the history was not scanned
+		err = patches.Wait()
+	}
+	// the findings held back by holdAbsent are reported however the scan
+	// ended
+	if absentErr := d.reportAbsent(ctx, source, stream.send); err == nil {
+		err = absentErr
+	}
+	if err != nil {
+		return err
 	}
 	log.Debug().Msgf("%d commits scanned. Note: this number might be smaller than expected due to commits with no additions", len(d.commitMap))
-	return d.findings, nil
+	d.logIgnored()
+	if d.scanCache != nil && d.scanCache.tips != nil {
+		if stream.sent != 0 {
+			// leave the commits with findings to be scanned and
+			// reported again until the findings are dealt with
+			log.Info().Msg("leaks found, the scan cache is not updated")
+			return nil
+		}
+		if err := d.scanCache.save(); err != nil {
+			log.Warn().Err(err).Msg("failed to save the scan cache")
+		}
+	}
+	return nil
 }
 
 // DetectFiles accepts a path to a source directory or file and begins a scan of the
-// file or directory.
-func (d *Detector) DetectFiles(source string) ([]report.Finding, error) {
-	s := semgroup.NewGroup(context.Background(), 4)
+// file or directory. The scan stops early and returns ctx.Err() if ctx is cancelled.
+func (d *Detector) DetectFiles(ctx context.Context, source string) ([]report.Finding, error) {
+	err := d.DetectFilesStream(ctx, source, d.appendFinding)
+	return d.findings, err
+}
+
+// DetectFilesStream scans a directory or file like DetectFiles but hands each
+// finding to onFinding as soon as it is found instead of accumulating findings
+// in memory. onFinding is never called concurrently. If onFinding returns an
+// error the scan is stopped and that error is returned.
+func (d *Detector) DetectFilesStream(ctx context.Context, source string, onFinding func(report.Finding) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream := newFindingStream(onFinding, cancel)
+	// one extra worker for the directory walker so it never
+	// starves the workers reading files
+	s := semgroup.NewGroup(ctx, d.workers()+1)
+
 	paths := make(chan string)
 	s.Go(func() error {
 		defer close(paths)
@@ -335,69 +579,112 @@ func (d *Detector) DetectFiles(source string) ([]report.Finding, error) {
 				if err != nil {
 					return err
 				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				if fInfo.Name() == ".git" && fInfo.IsDir() {
 					return filepath.SkipDir
 				}
-				if fInfo.Mode().IsRegular() {
-					paths <- path
+				if !fInfo.Mode().IsRegular() {
+					return nil
+				}
+				if d.tooLarge(fInfo.Size()) {
+					d.addSkippedFile(path, fInfo.Size())
+					return nil
 				}
+				paths <- path
 				return nil
 			})
 	})
 	for pa := range paths {
 		p := pa
+		if ctx.Err() != nil {
+			continue
+		}
 		s.Go(func() error {
-			b, err := os.ReadFile(p)
-			if err != nil {
-				return err
-			}
-
-			mimetype, err := filetype.Match(b)
-			if err != nil {
-				return err
-			}
-			if mimetype.MIME.Type == "application" {
-				return nil // skip binary files
-			}
-
-			fragment := Fragment{
-				Raw:      string(b),
-				FilePath: p,
-			}
-			for _, finding := range d.Detect(fragment) {
-				// need to add 1 since line counting starts at 1
-				finding.EndLine++
-				finding.StartLine++
-				d.addFinding(finding)
-			}
-
-			return nil
+			return d.detectFile(ctx, p, func(finding report.Finding) error {
+				if d.isIgnored(finding) {
+					return nil
+				}
+				return d.addFinding(ctx, finding, stream.send)
+			})
 		})
 	}
 
-	if err := s.Wait(); err != nil {
-		return d.findings, err
+	if err := stream.wait(ctx, s); err != nil {
+		return err
+	}
+	d.logIgnored()
+	if d.skippedFiles != 0 {
+		log.Info().Msgf("%d files skipped because they are larger than %d MB", d.skippedFiles, d.MaxTargetMegaBytes)
 	}
 
-	return d.findings, nil
+	return nil
 }
 
-// Detect scans the given fragment and returns a list of findings
+// Detect scans the given fragment and returns a list of findings. Findings
+// suppressed by gitleaks:allow comments are left out.
 func (d *Detector) Detect(fragment Fragment) []report.Finding {
 	var findings []report.Finding
+	for _, finding := range d.detectFragment(fragment) {
+		if finding.Suppression == nil {
+			d.verify(context.Background(), &finding, finding.Secret)
+			if d.Redact {
+				finding.Redact()
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
 
-	// initiate fragment keywords
-	fragment.keywords = make(map[string]bool)
+// detectFragment scans the given fragment like Detect but also returns the
+// findings suppressed by gitleaks:allow comments, so they can be handed to
+// OnSuppressed.
+func (d *Detector) detectFragment(fragment Fragment) []report.Finding {
+	return d.resolveFindings(d.scanFragment(fragment))
+}
 
+// scanFragment scans the given fragment like detectFragment but leaves the
+// requirements of composite rules unresolved, so the findings of several
+// fragments of a file can be resolved together with resolveFindings.
+func (d *Detector) scanFragment(fragment Fragment) []report.Finding {
 	// check if filepath is allowed
-	if fragment.FilePath != "" && (d.Config.Allowlist.PathAllowed(fragment.FilePath) ||
-		fragment.FilePath == d.Config.Path) {
-		return findings
+	if d.pathAllowed(fragment.FilePath) {
+		return nil
 	}
+	return d.detect(fragment, nil)
+}
+
+// resolveFindings resolves the requirements of composite rules among the
+// findings of a file and drops generic findings that a more specific rule
+// also found. Line numbers of the findings must be relative to the same
+// start, e.g. the start of the file.
+func (d *Detector) resolveFindings(findings []report.Finding) []report.Finding {
+	return filter(d.applyRequired(findings))
+}
+
+// pathAllowed returns true if the file at path is allowlisted or is the
+// gitleaks config itself
+func (d *Detector) pathAllowed(path string) bool {
+	return path != "" && (d.Config.Allowlist.PathAllowed(path) || path == d.Config.Path)
+}
+
+// detect scans the given fragment with the rules of the config and, up to
+// MaxDecodeDepth, the text decoded from encoded blobs in the fragment.
+// encodings lists the decodings that produced fragment, if any.
+func (d *Detector) detect(fragment Fragment, encodings []string) []report.Finding {
+	var findings []report.Finding
+
+	// initiate fragment keywords
+	fragment.keywords = make(map[string]bool)
 
 	// add newline indices for location calculation in detectRule
 	fragment.newlineIndices = regexp.MustCompile("\n").FindAllStringIndex(fragment.Raw, -1)
 
+	// find regions in which findings are suppressed
+	fragment.allowRegions = fragmentAllowRegions(fragment.Raw, fragment.openAllowRegion)
+
 	// build keyword map for prefiltering rules
 	normalizedRaw := strings.ToLower(fragment.Raw)
 	matches := d.prefilter.FindAll(normalizedRaw)
@@ -406,6 +693,11 @@ func (d *Detector) Detect(fragment Fragment) []report.Finding {
 	}
 
 	for _, rule := range d.Config.Rules {
+		if len(encodings) > 0 && rule.Regex == nil {
+			// path only rules have already been checked against
+			// the fragment the decoded text comes from
+			continue
+		}
 		if len(rule.Keywords) == 0 {
 			// if not keywords are associated with the rule always scan the
 			// fragment using the rule
@@ -423,19 +715,105 @@ func (d *Detector) Detect(fragment Fragment) []report.Finding {
 			findings = append(findings, d.detectRule(fragment, rule)...)
 		}
 	}
-	return filter(findings, d.Redact)
+
+	// note which decodings produced findings in decoded text
+	if len(encodings) > 0 {
+		for i := range findings {
+			findings[i].Tags = decodedTags(findings[i].Tags, encodings)
+		}
+	}
+
+	return append(findings, d.detectDecoded(fragment, encodings)...)
 }
 
-// addFinding synchronously adds a finding to the findings slice
-func (d *Detector) addFinding(finding report.Finding) {
+// addFinding synchronously hands a finding to onFinding unless the
+// finding is present in the baseline. Findings are redacted here rather
+// than when they are detected so the secret can still be verified and
+// looked up at HEAD.
+func (d *Detector) addFinding(ctx context.Context, finding report.Finding, onFinding func(report.Finding) error) error {
+	secret := finding.Secret
+	if d.Redact {
+		finding.Redact()
+	}
+	if finding.Suppression != nil {
+		return d.addSuppressed(finding)
+	}
+	if d.baseline != nil && !IsNew(finding, d.baseline) {
+		log.Debug().Msgf("baseline duplicate -- ignoring finding with rule %s in %s", finding.RuleID, finding.File)
+		return nil
+	}
+	// callers drop the findings listed in .gitleaksignore before calling
+	// addFinding, so accepted secrets are never sent to their provider
+	d.verify(ctx, &finding, secret)
 	d.findingMutex.Lock()
-	d.findings = append(d.findings, finding)
+	defer d.findingMutex.Unlock()
 	if d.Verbose {
 		printFinding(finding)
 	}
+	return onFinding(finding)
+}
+
+// appendFinding adds a finding to the findings slice. It is used as the
+// onFinding callback for DetectGit and DetectFiles.
+func (d *Detector) appendFinding(finding report.Finding) error {
+	d.findings = append(d.findings, finding)
+	return nil
+}
+
+// addSuppressed counts a finding suppressed by a gitleaks:allow comment and
+// hands it to OnSuppressed
+func (d *Detector) addSuppressed(finding report.Finding) error {
+	d.findingMutex.Lock()
+	defer d.findingMutex.Unlock()
+	d.suppressedCount++
+	if d.OnSuppressed == nil {
+		return nil
+	}
+	return d.OnSuppressed(finding)
+}
+
+// logIgnored logs how many findings were dropped by the .gitleaksignore file
+// and how many were suppressed by gitleaks:allow comments
+func (d *Detector) logIgnored() {
+	if d.ignoredCount != 0 {
+		log.Info().Msgf("%d findings ignored via %s", d.ignoredCount, gitleaksIgnoreFileName)
+	}
+	if d.suppressedCount != 0 {
+		log.Info().Msgf("%d findings suppressed by %s comments", d.suppressedCount, gitleaksAllowSignature)
+	}
+}
+
+// tooLarge returns true if a file of the given size exceeds MaxTargetMegaBytes
+func (d *Detector) tooLarge(size int64) bool {
+	maxSize := d.maxTargetBytes()
+	return maxSize > 0 && size > maxSize
+}
+
+// maxTargetBytes returns MaxTargetMegaBytes in bytes, 0 if there is no limit
+func (d *Detector) maxTargetBytes() int64 {
+	if d.MaxTargetMegaBytes <= 0 {
+		return 0
+	}
+	return int64(d.MaxTargetMegaBytes) * 1024 * 1024
+}
+
+// addSkippedFile records a file that is not scanned because it exceeds MaxTargetMegaBytes
+func (d *Detector) addSkippedFile(path string, size int64) {
+	log.Debug().Msgf("skipping %s, size %d bytes exceeds --max-target-megabytes=%d",
+		path, size, d.MaxTargetMegaBytes)
+	d.findingMutex.Lock()
+	d.skippedFiles++
 	d.findingMutex.Unlock()
 }
 
+// workers returns the number of concurrent workers to use for a scan
+func (d *Detector) workers() int64 {
+	if d.Threads < 1 {
+		return 1
+	}
+	return int64(d.Threads)
+}
+
 // addCommit synchronously adds a commit to the commit slice
 func (d *Detector) addCommit(commit string) {
 	d.commitMap[commit] = true
@@ -0,0 +1,271 @@
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/h2non/filetype"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+const (
+	// defaultChunkSize is the amount of a file that is read and scanned as a
+	// single fragment. Files smaller than this are scanned in one go.
+	defaultChunkSize = 10 * 1024 * 1024
+
+	// defaultChunkOverlap is the amount of the end of a chunk that is scanned
+	// again at the start of the next chunk, so that secrets crossing a chunk
+	// boundary, like private keys, are still detected.
+	defaultChunkOverlap = 64 * 1024
+
+	// fileTypeHeaderSize is the number of bytes filetype needs to match
+	// a file type
+	fileTypeHeaderSize = 262
+)
+
+// detectFile scans the file at path and hands each finding to onFinding.
+func (d *Detector) detectFile(ctx context.Context, path string, onFinding func(report.Finding) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.detectReader(ctx, path, f, 0, onFinding)
+}
+
+// detectReader scans the content of r, which is reported as path in findings.
+// Archives are opened if ScanArchives is set and depth, the number of archives
+// r is nested in, is below MaxArchiveDepth. Other binary content is skipped.
+func (d *Detector) detectReader(ctx context.Context, path string, r io.Reader, depth int,
+	onFinding func(report.Finding) error) error {
+	_, chunkOverlap := d.chunkSizes()
+	br := bufio.NewReaderSize(r, chunkOverlap)
+	header, err := br.Peek(fileTypeHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	mimetype, err := filetype.Match(header)
+	if err != nil {
+		return err
+	}
+	if d.ScanArchives && isArchive(mimetype) {
+		if depth >= d.MaxArchiveDepth {
+			log.Debug().Msgf("skipping %s, archive depth exceeds --max-archive-depth=%d", path, d.MaxArchiveDepth)
+			return nil
+		}
+		return d.detectArchive(ctx, path, mimetype, r, br, depth+1, onFinding)
+	}
+	if mimetype.MIME.Type == "application" {
+		return nil // skip binary files
+	}
+	return d.detectChunks(ctx, path, br, onFinding)
+}
+
+// detectChunks scans r in chunks of chunkSize bytes so memory stays bounded
+// for large files. Each chunk is extended to the end of its last line and is
+// prefixed with up to chunkOverlap bytes from the end of the previous chunk.
+// Finding locations are adjusted to be relative to the start of the file.
+// If the config has composite rules, the findings of all chunks are kept
+// until the end of the file so requirements are resolved across chunks.
+func (d *Detector) detectChunks(ctx context.Context, path string, r *bufio.Reader,
+	onFinding func(report.Finding) error) error {
+	if !d.hasComposite() {
+		return d.scanChunks(ctx, []string{path}, r, func(findings []report.Finding) error {
+			for _, finding := range d.resolveFindings(findings) {
+				if err := onFinding(finding); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	var fileFindings []report.Finding
+	err := d.scanChunks(ctx, []string{path}, r, func(findings []report.Finding) error {
+		fileFindings = append(fileFindings, findings...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, finding := range d.resolveFindings(fileFindings) {
+		if err := onFinding(finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanChunks reads r in chunks like detectChunks and hands the findings of
+// each chunk to onFindings, leaving the requirements of composite rules
+// unresolved. Findings in the overlap of two chunks are only handed over once.
+// The content is scanned as the content of a file at each of paths, so the
+// same content found at several paths is only read once.
+func (d *Detector) scanChunks(ctx context.Context, paths []string, r *bufio.Reader,
+	onFindings func([]report.Finding) error) error {
+	chunkSize, chunkOverlap := d.chunkSizes()
+
+	var (
+		// overlap is the end of the previous chunk which is scanned again
+		overlap []byte
+
+		// startLine and startColumn are the 0-indexed position in the file
+		// of the first byte of the current fragment
+		startLine   int
+		startColumn int
+
+		// previous holds the findings of the previous fragment so findings
+		// in the overlap are not reported twice
+		previous map[string]bool
+
+		// openRegion is the gitleaks:allow-begin region the previous
+		// fragment left open where the current one starts
+		openRegion *allowComment
+
+		buf = make([]byte, chunkSize)
+	)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, err := io.ReadFull(r, buf)
+		eof := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !eof {
+			return err
+		}
+		if n == 0 && overlap == nil {
+			return nil // empty file
+		}
+
+		raw := append(overlap, buf[:n]...)
+		if !eof {
+			// finish the current line, unless it is longer than the
+			// overlap in which case the chunk ends mid-line
+			rest, err := r.ReadSlice('\n')
+			if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+				if !errors.Is(err, io.EOF) {
+					return err
+				}
+				eof = true
+			}
+			raw = append(raw, rest...)
+		}
+
+		content := string(raw)
+		singleLine := bytes.IndexByte(raw, '\n') < 0
+		current := make(map[string]bool)
+		var findings []report.Finding
+		for _, path := range paths {
+			fragment := Fragment{
+				Raw:             content,
+				FilePath:        path,
+				openAllowRegion: openRegion,
+			}
+			for _, finding := range d.scanFragment(fragment) {
+				finding = adjustChunkFinding(finding, singleLine, startLine, startColumn)
+				key := fmt.Sprintf("%s:%s:%d:%d:%s", finding.File, finding.RuleID, finding.StartLine,
+					finding.StartColumn, finding.Match)
+				current[key] = true
+				if !previous[key] {
+					findings = append(findings, finding)
+				}
+			}
+		}
+		if err := onFindings(findings); err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+		previous = current
+
+		// carry the end of this fragment over to the next one, starting
+		// at a line boundary if there is one in the overlap
+		overlapStart := len(raw) - chunkOverlap
+		if overlapStart < 0 {
+			overlapStart = 0
+		}
+		if i := bytes.IndexByte(raw[overlapStart:], '\n'); i >= 0 {
+			overlapStart += i + 1
+		}
+		if newlines := bytes.Count(raw[:overlapStart], []byte("\n")); newlines > 0 {
+			startLine += newlines
+			startColumn = overlapStart - (bytes.LastIndexByte(raw[:overlapStart], '\n') + 1)
+		} else {
+			startColumn += overlapStart
+		}
+		overlap = append([]byte(nil), raw[overlapStart:]...)
+
+		// a region open where the overlap starts still applies in the
+		// next fragment, whose content no longer holds its begin marker
+		openRegion = openAllowRegion(fragmentAllowRegions(content, openRegion), overlapStart)
+	}
+}
+
+// adjustChunkFinding updates the location of a finding, relative to a
+// fragment that starts at the 0-indexed startLine and startColumn of a file,
+// to be relative to the start of the file. singleLine is set if the fragment
+// does not contain a newline.
+func adjustChunkFinding(finding report.Finding, singleLine bool, startLine int, startColumn int) report.Finding {
+	// location() reports matches in a fragment without any newline
+	// as being on line 1 instead of line 0
+	if singleLine && finding.StartLine > 0 {
+		finding.StartLine--
+		finding.EndLine--
+	}
+
+	if strings.HasPrefix(finding.Match, "file detected") {
+		// path only findings have no location, keep them identical
+		// across chunks so they are only reported once
+		finding.StartLine++
+		finding.EndLine++
+		return finding
+	}
+
+	// columns of all but the first line of a fragment are reported one
+	// higher by location(), keep that consistent across chunk boundaries
+	columnOffset := startColumn
+	if startLine > 0 {
+		columnOffset++
+	}
+	if finding.StartLine == 0 && finding.StartColumn != 0 {
+		finding.StartColumn += columnOffset
+	}
+	if finding.EndLine == 0 && finding.EndColumn != 0 {
+		finding.EndColumn += columnOffset
+	}
+
+	// need to add 1 since line counting starts at 1
+	finding.StartLine += startLine + 1
+	finding.EndLine += startLine + 1
+	return finding
+}
+
+// chunkSizes returns the chunk size and overlap used by detectFile
+func (d *Detector) chunkSizes() (int, int) {
+	chunkSize, chunkOverlap := d.chunkSize, d.chunkOverlap
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	if chunkOverlap <= 0 || chunkOverlap >= chunkSize {
+		chunkOverlap = defaultChunkOverlap
+		if chunkOverlap >= chunkSize {
+			chunkOverlap = chunkSize / 2
+		}
+	}
+	// bufio needs a buffer large enough to peek the file type header
+	if chunkOverlap < fileTypeHeaderSize {
+		chunkOverlap = fileTypeHeaderSize
+	}
+	return chunkSize, chunkOverlap
+}
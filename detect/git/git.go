@@ -2,63 +2,240 @@ package git
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
-	"time"
+	"unicode"
 
 	"github.com/gitleaks/go-gitdiff/gitdiff"
 	"github.com/rs/zerolog/log"
 )
 
-// GitLog returns a channel of gitdiff.File objects from the
-// git log -p command for the given source.
-func GitLog(source string, logOpts string) (<-chan *gitdiff.File, error) {
+// StderrClassifier tells whether a line git wrote to stderr is a benign
+// warning, after which the output of the command is still complete and
+// correct. Lines that are not benign make the command fail.
+type StderrClassifier func(line string) bool
+
+// BenignWarnings returns a StderrClassifier that treats the lines matching
+// any of patterns as benign
+func BenignWarnings(patterns ...*regexp.Regexp) StderrClassifier {
+	return func(line string) bool {
+		for _, pattern := range patterns {
+			if pattern.MatchString(line) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// DefaultStderrClassifier treats the warnings git writes when it skips rename
+// detection in commits that change too many files as benign, git log -p and
+// git diff still write all patches when that happens
+var DefaultStderrClassifier = BenignWarnings(
+	regexp.MustCompile(`^warning: (exhaustive|inexact) rename detection was skipped`),
+	regexp.MustCompile(`^warning: you may want to set your diff\.renameLimit`),
+)
+
+// CommandError is returned when a git command exits with an error or writes
+// lines to stderr that are not benign
+type CommandError struct {
+	// Args are the arguments git was run with
+	Args []string
+
+	// ExitCode is the exit status of git, 0 if it exited successfully
+	// but wrote an error to stderr, -1 if it was killed
+	ExitCode int
+
+	// Stderr is what git wrote to stderr, less the benign warnings
+	Stderr string
+
+	// Err is the error returned when waiting for git, if any
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s failed", strings.Join(e.Args, " "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// PatchCmd is a running git command whose patches are parsed into
+// gitdiff.File objects
+type PatchCmd struct {
+	cmd   *exec.Cmd
+	files chan *gitdiff.File
+	done  chan struct{}
+	err   error
+}
+
+// Files returns the files of the patches written by git. The channel is
+// closed once git has exited or failed.
+func (p *PatchCmd) Files() <-chan *gitdiff.File {
+	return p.files
+}
+
+// Wait returns a *CommandError if git failed. It must be called after the
+// channel returned by Files is drained.
+func (p *PatchCmd) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// GitLog runs git log -p for the given source and parses its patches. The
+// git process is killed if ctx is cancelled before it finishes. Lines git
+// writes to stderr are classified with classify, DefaultStderrClassifier
+// if nil.
+func GitLog(ctx context.Context, source string, logOpts string, classify StderrClassifier) (*PatchCmd, error) {
 	sourceClean := filepath.Clean(source)
 	var cmd *exec.Cmd
 	if logOpts != "" {
-		args := []string{"-C", sourceClean, "log", "-p", "-U0"}
-		args = append(args, strings.Split(logOpts, " ")...)
-		cmd = exec.Command("git", args...)
+		opts, err := splitLogOpts(logOpts)
+		if err != nil {
+			return nil, err
+		}
+		args := append([]string{"-C", sourceClean, "log", "-p", "-U0"}, opts...)
+		cmd = exec.CommandContext(ctx, "git", args...)
 	} else {
-		cmd = exec.Command("git", "-C", sourceClean, "log", "-p", "-U0",
+		cmd = exec.CommandContext(ctx, "git", "-C", sourceClean, "log", "-p", "-U0",
 			"--full-history", "--all")
 	}
+	return startPatchCmd(cmd, classify)
+}
 
-	log.Debug().Msgf("executing: %s", cmd.String())
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
+// splitLogOpts splits log options into arguments at whitespace like a shell
+// does, arguments containing whitespace can be quoted with single or double
+// quotes, e.g. --author="Jane Doe". A backslash escapes the next character
+// outside of quotes, and a double quote or backslash inside double quotes.
+func splitLogOpts(logOpts string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range logOpts {
+		switch {
+		case escaped:
+			// inside double quotes only a few characters can be
+			// escaped, the backslash is kept before the others
+			if quote == '"' && c != '"' && c != '\\' {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case unicode.IsSpace(c):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in log options %s", quote, logOpts)
 	}
-	if err := cmd.Start(); err != nil {
-		return nil, err
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in log options %s", logOpts)
 	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
 
-	go listenForStdErr(stderr)
-	// HACK: to avoid https://github.com/zricethezav/gitleaks/issues/722
-	time.Sleep(50 * time.Millisecond)
-
-	return gitdiff.Parse(stdout)
+// GitLogRevisions is like GitLog without log options, but only logs the
+// commits reachable from include that are not reachable from exclude. The
+// revisions are passed on stdin so there can be any number of them, and
+// revisions that no longer exist in the repo are ignored.
+func GitLogRevisions(ctx context.Context, source string, include []string, exclude []string,
+	classify StderrClassifier) (*PatchCmd, error) {
+	var revisions strings.Builder
+	for _, rev := range include {
+		revisions.WriteString(rev + "\n")
+	}
+	for _, rev := range exclude {
+		revisions.WriteString("^" + rev + "\n")
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", filepath.Clean(source), "log", "-p", "-U0",
+		"--full-history", "--ignore-missing", "--stdin")
+	cmd.Stdin = strings.NewReader(revisions.String())
+	return startPatchCmd(cmd, classify)
 }
 
-// GitDiff returns a channel of gitdiff.File objects from
-// the git diff command for the given source.
-func GitDiff(source string, staged bool) (<-chan *gitdiff.File, error) {
+// GitDiff runs git diff for the given source and parses its patches. The
+// git process is killed if ctx is cancelled before it finishes. Lines git
+// writes to stderr are classified with classify, DefaultStderrClassifier
+// if nil.
+func GitDiff(ctx context.Context, source string, staged bool, classify StderrClassifier) (*PatchCmd, error) {
 	sourceClean := filepath.Clean(source)
 	var cmd *exec.Cmd
-	cmd = exec.Command("git", "-C", sourceClean, "diff", "-U0", ".")
+	cmd = exec.CommandContext(ctx, "git", "-C", sourceClean, "diff", "-U0", ".")
 	if staged {
-		cmd = exec.Command("git", "-C", sourceClean, "diff", "-U0",
+		cmd = exec.CommandContext(ctx, "git", "-C", sourceClean, "diff", "-U0",
 			"--staged", ".")
 	}
+	return startPatchCmd(cmd, classify)
+}
+
+// RefTips returns the commits that the refs of the repo at source and HEAD
+// point to, which are the commits git log --all starts from
+func RefTips(ctx context.Context, source string) ([]string, error) {
+	sourceClean := filepath.Clean(source)
+	out, err := exec.CommandContext(ctx, "git", "-C", sourceClean, "rev-parse", "--all").Output()
+	if err != nil {
+		return nil, err
+	}
+	// HEAD does not resolve in a repo without commits
+	head, _ := exec.CommandContext(ctx, "git", "-C", sourceClean, "rev-parse", "--verify", "-q", "HEAD").Output()
+
+	var (
+		tips []string
+		seen = make(map[string]bool)
+	)
+	for _, tip := range strings.Fields(string(out) + " " + string(head)) {
+		if !seen[tip] {
+			seen[tip] = true
+			tips = append(tips, tip)
+		}
+	}
+	return tips, nil
+}
+
+// startPatchCmd starts cmd and parses the patches it writes to stdout
+func startPatchCmd(cmd *exec.Cmd, classify StderrClassifier) (*PatchCmd, error) {
 	log.Debug().Msgf("executing: %s", cmd.String())
+	if classify == nil {
+		classify = DefaultStderrClassifier
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -72,41 +249,67 @@ func GitDiff(source string, staged bool) (<-chan *gitdiff.File, error) {
 		return nil, err
 	}
 
-	go listenForStdErr(stderr)
-	// HACK: to avoid https://github.com/zricethezav/gitleaks/issues/722
-	time.Sleep(50 * time.Millisecond)
+	p := &PatchCmd{
+		cmd:   cmd,
+		files: make(chan *gitdiff.File),
+		done:  make(chan struct{}),
+	}
+	stderrLines := make(chan string, 1)
+	go func() {
+		stderrLines <- readStderr(stderr, classify)
+	}()
 
-	return gitdiff.Parse(stdout)
+	files, err := gitdiff.Parse(stdout)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	go p.forward(stdout, stderrLines, files)
+	return p, nil
 }
 
-// listenForStdErr listens for stderr output from git and prints it to stdout
-// then exits with exit code 1
-func listenForStdErr(stderr io.ReadCloser) {
+// forward forwards the parsed files and, once the parser is done, waits for
+// the git process to exit so it does not linger after the scan
+func (p *PatchCmd) forward(stdout io.Reader, stderrLines <-chan string, files <-chan *gitdiff.File) {
+	defer close(p.done)
+	for f := range files {
+		p.files <- f
+	}
+	close(p.files)
+	// the parser can stop before reaching the end of the output, drain
+	// what is left so git does not block writing to a full pipe
+	_, _ = io.Copy(io.Discard, stdout)
+	stderr := <-stderrLines
+
+	p.err = commandError(p.cmd, stderr, p.cmd.Wait())
+}
+
+// commandError returns a *CommandError if cmd exited with err or wrote the
+// lines in stderr that are not benign, nil if it succeeded
+func commandError(cmd *exec.Cmd, stderr string, err error) error {
+	if err == nil && stderr == "" {
+		return nil
+	}
+	cmdErr := &CommandError{Args: cmd.Args, Stderr: stderr, Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	return cmdErr
+}
+
+// readStderr reads what git writes to stderr, logs the lines classify finds
+// benign and returns the other lines
+func readStderr(stderr io.Reader, classify StderrClassifier) string {
+	var lines []string
 	scanner := bufio.NewScanner(stderr)
-	errEncountered := false
 	for scanner.Scan() {
-		// if git throws the following error:
-		//
-		//  exhaustive rename detection was skipped due to too many files.
-		//  you may want to set your diff.renameLimit variable to at least
-		//  (some large number) and retry the command.
-		//
-		// we skip exiting the program as git log -p/git diff will continue
-		// to send data to stdout and finish executing. This next bit of
-		// code prevents gitleaks from stopping mid scan if this error is
-		// encountered
-		if strings.Contains(scanner.Text(),
-			"exhaustive rename detection was skipped") ||
-			strings.Contains(scanner.Text(),
-				"you may want to set your diff.renameLimit") {
-
+		if classify(scanner.Text()) {
 			log.Warn().Msg(scanner.Text())
 		} else {
-			log.Error().Msg(scanner.Text())
-			errEncountered = true
+			lines = append(lines, scanner.Text())
 		}
 	}
-	if errEncountered {
-		os.Exit(1)
-	}
+	return strings.Join(lines, "\n")
 }
@@ -1,5 +1,14 @@
 package git_test
 
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+)
+
 // TODO: commenting out this test for now because it's flaky. Alternatives to consider to get this working:
 // -- use `git stash` instead of `restore()`
 
@@ -156,3 +165,19 @@ package git_test
 // 	}
 // 	return nil
 // }
+
+func TestDefaultStderrClassifier(t *testing.T) {
+	benign := []string{
+		"warning: exhaustive rename detection was skipped due to too many files.",
+		"warning: inexact rename detection was skipped due to too many files.",
+		"warning: you may want to set your diff.renameLimit variable to at least 1392 and retry the command.",
+	}
+	for _, line := range benign {
+		assert.True(t, git.DefaultStderrClassifier(line), line)
+	}
+	assert.False(t, git.DefaultStderrClassifier("fatal: bad revision 'no-such-branch'"))
+
+	classify := git.BenignWarnings(regexp.MustCompile(`^warning: refname '.*' is ambiguous`))
+	assert.True(t, classify("warning: refname 'main' is ambiguous."))
+	assert.False(t, classify(benign[0]))
+}
@@ -0,0 +1,162 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultBranch returns the ref of the branch origin/HEAD points to in the
+// repo at source, which is the branch pull requests are merged into. HEAD is
+// returned if the repo has no origin/HEAD, e.g. if it was not cloned.
+func DefaultBranch(ctx context.Context, source string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", filepath.Clean(source), "symbolic-ref", "-q",
+		"refs/remotes/origin/HEAD").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// git symbolic-ref exits with an error if the ref does not exist
+		return "HEAD", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// HeadContains reports whether secret is in any file of the tree at ref of
+// the repo at source. Secrets spanning several lines are considered present
+// if a single file contains all of their lines. The secret is passed to git
+// on stdin so it does not show up in the process list.
+func HeadContains(ctx context.Context, source string, ref string, secret string) (bool, error) {
+	var patterns strings.Builder
+	for _, line := range strings.Split(secret, "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			patterns.WriteString(line + "\n")
+		}
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", filepath.Clean(source), "grep", "-q", "-F", "--all-match",
+		"-f", "-", ref, "--")
+	cmd.Stdin = strings.NewReader(patterns.String())
+	log.Debug().Msgf("executing: git grep %s in %s", ref, source)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// git grep exits with 1 if nothing matched
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Removals records the commits of a history that removed secrets from files,
+// see RemovedIn
+type Removals struct {
+	// commits maps each secret to the files it was removed from and the
+	// commit that removed it. Files are keyed by their name at the most
+	// recent commit that changed them.
+	commits map[string]map[string]string
+
+	// names maps every name a file had in the history to its key in
+	// commits
+	names map[string]string
+}
+
+// Commit returns the commit that removed secret from the file that was named
+// path at some point of the history, or "" if there is none
+func (r *Removals) Commit(secret string, path string) string {
+	key, ok := r.names[path]
+	if !ok {
+		key = path
+	}
+	return r.commits[secret][key]
+}
+
+// RemovedIn looks up, for each of secrets and each file of the repo at source,
+// the last commit reachable from ref that changed the number of occurrences of
+// the secret in the file, like git log -S does. When the secret is no longer
+// in the tree at ref, that is the commit that removed it. Renames are followed
+// so a secret removed after its file was renamed is found by either name. The
+// history is walked once for all secrets, and the patches are matched here
+// rather than passing the secrets to git log -S, which would show them in the
+// process list.
+func RemovedIn(ctx context.Context, source string, ref string, secrets []string,
+	classify StderrClassifier) (*Removals, error) {
+	removals := &Removals{
+		commits: make(map[string]map[string]string),
+		names:   make(map[string]string),
+	}
+	if len(secrets) == 0 {
+		return removals, nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", filepath.Clean(source), "log", "-p", "-U0", "-M", ref, "--")
+	patches, err := startPatchCmd(cmd, classify)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{MatchKind: ahocorasick.StandardMatch})
+	matcher := builder.Build(secrets)
+	// count adds n for each occurrence of a secret in raw to changes,
+	// keyed by the index of the secret
+	count := func(raw string, n int, changes map[int]int) {
+		iter := matcher.IterOverlapping(raw)
+		for match := iter.Next(); match != nil; match = iter.Next() {
+			changes[match.Pattern()] += n
+		}
+	}
+
+	// renamed maps the names files had before a rename, further back in
+	// the history than the commits walked so far, to their key
+	renamed := make(map[string]string)
+	for file := range patches.Files() {
+		if file.PatchHeader == nil {
+			continue
+		}
+		path := file.NewName
+		if file.IsDelete {
+			path = file.OldName
+		}
+		key, ok := renamed[path]
+		if !ok {
+			key = path
+		}
+		if _, ok := removals.names[path]; !ok {
+			removals.names[path] = key
+		}
+		if file.IsRename {
+			// older commits changed the file under its old name
+			delete(renamed, file.NewName)
+			renamed[file.OldName] = key
+		}
+
+		changes := make(map[int]int)
+		for _, fragment := range file.TextFragments {
+			count(fragment.Raw(gitdiff.OpDelete), 1, changes)
+			count(fragment.Raw(gitdiff.OpAdd), -1, changes)
+		}
+		for i, change := range changes {
+			if change == 0 {
+				continue
+			}
+			files, ok := removals.commits[secrets[i]]
+			if !ok {
+				files = make(map[string]string)
+				removals.commits[secrets[i]] = files
+			}
+			if _, ok := files[key]; !ok {
+				// git log lists the most recent commits first
+				files[key] = file.PatchHeader.SHA
+			}
+		}
+	}
+	if err := patches.Wait(); err != nil {
+		return nil, err
+	}
+	return removals, nil
+}
@@ -0,0 +1,475 @@
+package git
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// nativeRepository is a Repository that reads the objects and refs under
+// .git directly, so the git binary is not needed
+type nativeRepository struct {
+	gitDir string
+
+	// commonDir is the git directory holding the refs and objects, which
+	// is the one of the main work tree for linked work trees
+	commonDir string
+	objects   *objectStore
+}
+
+// NewNativeRepository returns a Repository that reads the loose objects,
+// packfiles and refs of the repository at source without running git.
+// source is a work tree, a bare repository or a .git directory. Renamed
+// files are only recognized as such when their content did not change.
+func NewNativeRepository(source string) (Repository, error) {
+	gitDir, err := findGitDir(source)
+	if err != nil {
+		return nil, err
+	}
+	commonDir := gitDir
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		// linked work trees share the refs and objects of the main
+		// repository, only HEAD is their own
+		commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	objects, err := openObjectStore(filepath.Join(commonDir, "objects"))
+	if err != nil {
+		return nil, err
+	}
+	return &nativeRepository{gitDir: gitDir, commonDir: commonDir, objects: objects}, nil
+}
+
+// findGitDir returns the git directory of the repository at source
+func findGitDir(source string) (string, error) {
+	dotGit := filepath.Join(source, ".git")
+	fileInfo, err := os.Stat(dotGit)
+	switch {
+	case err == nil && fileInfo.IsDir():
+		return dotGit, nil
+	case err == nil:
+		// work trees of submodules and linked work trees have a .git
+		// file pointing at the git directory
+		b, err := os.ReadFile(dotGit)
+		if err != nil {
+			return "", err
+		}
+		dir := strings.TrimSpace(strings.TrimPrefix(string(b), "gitdir:"))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(source, dir)
+		}
+		return dir, nil
+	}
+	// source may be a bare repository or a git directory itself
+	if _, err := os.Stat(filepath.Join(source, "objects")); err == nil {
+		if _, err := os.Stat(filepath.Join(source, "HEAD")); err == nil {
+			return source, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a git repository", source)
+}
+
+// refTips returns the objects the refs of the repository and HEAD point to
+func (r *nativeRepository) refTips() ([]string, error) {
+	refs := make(map[string]string)
+
+	// packed refs are overridden by loose refs of the same name
+	packed, err := os.ReadFile(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(packed), "\n") {
+		fields := strings.Fields(line)
+		// skip comments and the peeled ^<sha> lines of annotated tags
+		if len(fields) != 2 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	refsDir := filepath.Join(r.commonDir, "refs")
+	err = filepath.Walk(refsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(r.commonDir, path)
+		if err != nil {
+			return err
+		}
+		refs[filepath.ToSlash(name)] = strings.TrimSpace(string(b))
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var tips []string
+	seen := make(map[string]bool)
+	add := func(target string) {
+		// follow symbolic refs such as refs/remotes/origin/HEAD
+		for i := 0; i < 5 && strings.HasPrefix(target, "ref: "); i++ {
+			target = refs[strings.TrimSpace(strings.TrimPrefix(target, "ref: "))]
+		}
+		if len(target) == 40 && !seen[target] {
+			seen[target] = true
+			tips = append(tips, target)
+		}
+	}
+	if head, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD")); err == nil {
+		add(strings.TrimSpace(string(head)))
+	}
+	for _, target := range refs {
+		add(target)
+	}
+	return tips, nil
+}
+
+// nativeCommit is a parsed commit object
+type nativeCommit struct {
+	sha       string
+	tree      string
+	parents   []string
+	author    string
+	email     string
+	date      time.Time
+	committed time.Time
+	message   string
+}
+
+// readCommit reads a commit, peeling annotated tags. Commits missing from
+// the repository, such as the parents of the commits of a shallow clone, are
+// reported as not found rather than as an error.
+func (r *nativeRepository) readCommit(sha string) (*nativeCommit, bool, error) {
+	for i := 0; i < 10; i++ {
+		o, err := r.objects.read(sha, 0)
+		if errors.Is(err, errObjectNotFound) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		switch o.typ {
+		case objCommit:
+			return parseCommitObject(sha, o.data), true, nil
+		case objTag:
+			// the first header of a tag is "object <sha>"
+			line := string(o.data[:bytes.IndexByte(append(o.data, '\n'), '\n')])
+			sha = strings.TrimPrefix(line, "object ")
+		default:
+			// refs can point at trees and blobs as well
+			return nil, false, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// parseCommitObject parses the headers and message of a commit object
+func parseCommitObject(sha string, data []byte) *nativeCommit {
+	c := &nativeCommit{sha: sha}
+	headers, message := string(data), ""
+	if i := strings.Index(headers, "\n\n"); i >= 0 {
+		headers, message = headers[:i], headers[i+2:]
+	}
+	c.message = strings.TrimSpace(message)
+	for _, line := range strings.Split(headers, "\n") {
+		key, value := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			key, value = line[:i], line[i+1:]
+		}
+		switch key {
+		case "tree":
+			c.tree = value
+		case "parent":
+			c.parents = append(c.parents, value)
+		case "author":
+			c.author, c.email, c.date = parseIdentity(value)
+		case "committer":
+			_, _, c.committed = parseIdentity(value)
+		}
+	}
+	return c
+}
+
+// parseIdentity parses "Name <email> <unix time> <zone>"
+func parseIdentity(s string) (string, string, time.Time) {
+	start, end := strings.IndexByte(s, '<'), strings.LastIndexByte(s, '>')
+	if start < 0 || end < start {
+		return strings.TrimSpace(s), "", time.Time{}
+	}
+	name, email := strings.TrimSpace(s[:start]), s[start+1:end]
+	var date time.Time
+	if fields := strings.Fields(s[end+1:]); len(fields) > 0 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			date = time.Unix(seconds, 0)
+		}
+	}
+	return name, email, date
+}
+
+// commitQueue orders commits newest first by commit date, like git log
+type commitQueue []*nativeCommit
+
+func (q commitQueue) Len() int            { return len(q) }
+func (q commitQueue) Less(i, j int) bool  { return q[i].committed.After(q[j].committed) }
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*nativeCommit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+func (r *nativeRepository) Commits(ctx context.Context, fn func(Commit) error) error {
+	tips, err := r.refTips()
+	if err != nil {
+		return err
+	}
+	var (
+		queue commitQueue
+		seen  = make(map[string]bool)
+	)
+	push := func(sha string) error {
+		if seen[sha] {
+			return nil
+		}
+		seen[sha] = true
+		c, ok, err := r.readCommit(sha)
+		if err != nil || !ok {
+			return err
+		}
+		heap.Push(&queue, c)
+		return nil
+	}
+	for _, tip := range tips {
+		if err := push(tip); err != nil {
+			return err
+		}
+	}
+
+	for queue.Len() > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c := heap.Pop(&queue).(*nativeCommit)
+		for _, parent := range c.parents {
+			if err := push(parent); err != nil {
+				return err
+			}
+		}
+		commit := Commit{
+			SHA:     c.sha,
+			Author:  c.author,
+			Email:   c.email,
+			Date:    c.date,
+			Message: c.message,
+		}
+		// like git log, merge commits are not diffed
+		if len(c.parents) > 1 {
+			if err = fn(commit); err != nil {
+				return err
+			}
+			continue
+		}
+		parentTree := ""
+		if len(c.parents) == 1 {
+			parent, ok, err := r.readCommit(c.parents[0])
+			if err != nil {
+				return err
+			}
+			if ok {
+				parentTree = parent.tree
+			}
+		}
+		if commit.Changes, err = r.diffTrees(parentTree, c.tree); err != nil {
+			return fmt.Errorf("%s: %w", c.sha, err)
+		}
+		if err = fn(commit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treeEntry is an entry of a tree object
+type treeEntry struct {
+	mode string
+	sha  string
+}
+
+// readTree returns the entries of a tree object by name. An empty sha is
+// the empty tree.
+func (r *nativeRepository) readTree(sha string) (map[string]treeEntry, error) {
+	entries := make(map[string]treeEntry)
+	if sha == "" {
+		return entries, nil
+	}
+	o, err := r.objects.read(sha, 0)
+	if err != nil {
+		return nil, err
+	}
+	if o.typ != objTree {
+		return nil, fmt.Errorf("%s is not a tree", sha)
+	}
+	// entries are "<mode> <name>\0<20 byte id>"
+	data := o.data
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || nul+21 > len(data) {
+			return nil, fmt.Errorf("tree %s is corrupt", sha)
+		}
+		entries[string(data[space+1:nul])] = treeEntry{
+			mode: string(data[:space]),
+			sha:  hex.EncodeToString(data[nul+1 : nul+21]),
+		}
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// diffTrees returns the files that differ between two trees. Files that
+// were moved without changing are reported as renames.
+func (r *nativeRepository) diffTrees(oldTree string, newTree string) ([]Change, error) {
+	var changes []Change
+	deleted := make(map[string]string)
+	if err := r.diffTree(oldTree, newTree, "", &changes, deleted); err != nil {
+		return nil, err
+	}
+	for i, change := range changes {
+		if change.OldBlob != "" {
+			continue
+		}
+		if oldPath, ok := deleted[change.NewBlob]; ok {
+			changes[i].OldPath = oldPath
+			changes[i].OldBlob = change.NewBlob
+		}
+	}
+	return changes, nil
+}
+
+func (r *nativeRepository) diffTree(oldTree string, newTree string, prefix string, changes *[]Change, deleted map[string]string) error {
+	if oldTree == newTree {
+		return nil
+	}
+	oldEntries, err := r.readTree(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := r.readTree(newTree)
+	if err != nil {
+		return err
+	}
+
+	for name, entry := range newEntries {
+		path := prefix + name
+		old, existed := oldEntries[name]
+		switch {
+		case isTreeMode(entry.mode):
+			oldSubtree := ""
+			if existed && isTreeMode(old.mode) {
+				oldSubtree = old.sha
+			}
+			if err := r.diffTree(oldSubtree, entry.sha, path+"/", changes, deleted); err != nil {
+				return err
+			}
+		case isBlobMode(entry.mode):
+			change := Change{Path: path, OldPath: path, NewBlob: entry.sha}
+			if existed && isBlobMode(old.mode) {
+				if old.sha == entry.sha {
+					continue
+				}
+				change.OldBlob = old.sha
+			}
+			*changes = append(*changes, change)
+		}
+	}
+	for name, old := range oldEntries {
+		if entry, ok := newEntries[name]; ok && isTreeMode(entry.mode) == isTreeMode(old.mode) {
+			continue
+		}
+		if isTreeMode(old.mode) {
+			if err := r.collectDeleted(old.sha, prefix+name+"/", deleted); err != nil {
+				return err
+			}
+		} else if isBlobMode(old.mode) {
+			if _, ok := newEntries[name]; !ok {
+				deleted[old.sha] = prefix + name
+			}
+		}
+	}
+	return nil
+}
+
+// collectDeleted records the blobs of a deleted tree
+func (r *nativeRepository) collectDeleted(tree string, prefix string, deleted map[string]string) error {
+	entries, err := r.readTree(tree)
+	if err != nil {
+		return err
+	}
+	for name, entry := range entries {
+		if isTreeMode(entry.mode) {
+			if err := r.collectDeleted(entry.sha, prefix+name+"/", deleted); err != nil {
+				return err
+			}
+		} else if isBlobMode(entry.mode) {
+			deleted[entry.sha] = prefix + name
+		}
+	}
+	return nil
+}
+
+func isTreeMode(mode string) bool {
+	return mode == "40000"
+}
+
+// isBlobMode reports whether an entry is a file or a symlink, as opposed to
+// a tree or a submodule
+func isBlobMode(mode string) bool {
+	return strings.HasPrefix(mode, "100") || mode == "120000"
+}
+
+func (r *nativeRepository) Blobs(ctx context.Context, shas []string, maxSize int64,
+	fn func(sha string, size int64, content io.Reader) error) error {
+	for _, sha := range shas {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		o, err := r.objects.read(sha, maxSize)
+		if errors.Is(err, errObjectNotFound) {
+			continue
+		}
+		if errors.Is(err, errObjectTooLarge) {
+			log.Debug().Msgf("skipping blob %s, it is larger than %d bytes", sha, maxSize)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if o.typ != objBlob {
+			continue
+		}
+		// objects are read whole, deltas can only be applied to
+		// their whole base
+		if err = fn(sha, int64(len(o.data)), bytes.NewReader(o.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,512 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// object types as stored in packfiles
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objTypeNames = map[string]int{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+// errObjectNotFound is returned when an object is in none of the loose
+// object directories and packfiles of a repository
+var errObjectNotFound = errors.New("object not found")
+
+// errObjectTooLarge is returned when an object is larger than the size
+// it is read with
+var errObjectTooLarge = errors.New("object too large")
+
+// objectStore reads loose objects and packed objects from the object
+// directories of a repository
+type objectStore struct {
+	dirs  []string
+	packs []*packfile
+
+	// bases caches the objects that deltas were last applied to, since
+	// the objects of a delta chain are usually read one after the other
+	basesMutex sync.Mutex
+	bases      map[string]object
+}
+
+// maxCachedBases bounds the number of objects kept in objectStore.bases
+// and maxCachedBaseSize the size of each of them, larger bases are read
+// again for every delta on them
+const (
+	maxCachedBases    = 256
+	maxCachedBaseSize = 256 << 10
+)
+
+// maxDeltaDepth is the longest chain of deltas read, git's default for
+// pack.depth. Longer chains are only found in corrupt packs, e.g. ref
+// deltas that are each other's base.
+const maxDeltaDepth = 50
+
+// errDeltaTooDeep is returned for deltas whose chain is longer than
+// maxDeltaDepth
+var errDeltaTooDeep = errors.New("delta chain too deep")
+
+// object is an object read from the store
+type object struct {
+	typ  int
+	data []byte
+}
+
+// openObjectStore opens the object directory objectsDir and the object
+// directories it borrows objects from
+func openObjectStore(objectsDir string) (*objectStore, error) {
+	s := &objectStore{bases: make(map[string]object)}
+	if err := s.addDir(objectsDir, 0); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// addDir adds an object directory and the packfiles in it, followed by the
+// directories listed in its info/alternates file
+func (s *objectStore) addDir(dir string, depth int) error {
+	// git itself stops following alternates after 5 levels
+	if depth > 5 {
+		return nil
+	}
+	s.dirs = append(s.dirs, dir)
+
+	idxPaths, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+	if err != nil {
+		return err
+	}
+	for _, idxPath := range idxPaths {
+		p, err := openPackfile(strings.TrimSuffix(idxPath, ".idx"))
+		if err != nil {
+			return err
+		}
+		s.packs = append(s.packs, p)
+	}
+
+	alternates, err := os.ReadFile(filepath.Join(dir, "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, alternate := range strings.Split(string(alternates), "\n") {
+		alternate = strings.TrimSpace(alternate)
+		if alternate == "" || strings.HasPrefix(alternate, "#") {
+			continue
+		}
+		if !filepath.IsAbs(alternate) {
+			alternate = filepath.Join(dir, alternate)
+		}
+		if err = s.addDir(alternate, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *objectStore) close() {
+	for _, p := range s.packs {
+		p.close()
+	}
+}
+
+// read returns the object with the given hex sha. Objects larger than
+// maxSize bytes are not read and errObjectTooLarge is returned, 0 reads
+// objects of any size.
+func (s *objectStore) read(sha string, maxSize int64) (object, error) {
+	return s.readObject(sha, maxSize, 0)
+}
+
+// readObject reads an object as read does, depth is the number of deltas
+// already being resolved on it
+func (s *objectStore) readObject(sha string, maxSize int64, depth int) (object, error) {
+	id, err := hex.DecodeString(sha)
+	if err != nil || len(id) != 20 {
+		return object{}, fmt.Errorf("invalid object id %q", sha)
+	}
+	for _, p := range s.packs {
+		if offset, ok := p.find(id); ok {
+			return s.readPacked(p, offset, maxSize, depth)
+		}
+	}
+	for _, dir := range s.dirs {
+		o, err := readLooseObject(filepath.Join(dir, sha[:2], sha[2:]), maxSize)
+		if os.IsNotExist(err) {
+			continue
+		}
+		return o, err
+	}
+	return object{}, fmt.Errorf("%s: %w", sha, errObjectNotFound)
+}
+
+// readLooseObject reads the zlib compressed "<type> <size>\0<data>" object
+// at path unless it is larger than maxSize
+func readLooseObject(path string, maxSize int64) (object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return object{}, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return object{}, fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+	header, err := r.ReadString(0)
+	if err != nil {
+		return object{}, fmt.Errorf("%s: %w", path, err)
+	}
+	fields := strings.Fields(strings.TrimSuffix(header, "\x00"))
+	if len(fields) != 2 {
+		return object{}, fmt.Errorf("%s: invalid object header", path)
+	}
+	typ, ok := objTypeNames[fields[0]]
+	if !ok {
+		return object{}, fmt.Errorf("%s: unknown object type %s", path, fields[0])
+	}
+	size, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return object{}, fmt.Errorf("%s: invalid object size", path)
+	}
+	if maxSize > 0 && int64(size) > maxSize {
+		return object{}, fmt.Errorf("%s: %w", path, errObjectTooLarge)
+	}
+	data, err := readInflated(r, int64(size))
+	if err != nil {
+		return object{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return object{typ: typ, data: data}, nil
+}
+
+// readPacked reads the object at offset in a packfile, resolving deltas,
+// unless it is larger than maxSize. depth is the number of deltas already
+// being resolved on it.
+func (s *objectStore) readPacked(p *packfile, offset int64, maxSize int64, depth int) (object, error) {
+	typ, data, base, err := p.readEntry(offset, maxSize)
+	if err != nil {
+		return object{}, err
+	}
+	if typ != objOfsDelta && typ != objRefDelta {
+		return object{typ: typ, data: data}, nil
+	}
+	if depth >= maxDeltaDepth {
+		return object{}, fmt.Errorf("%s at %d: %w", p.path, offset, errDeltaTooDeep)
+	}
+	// the size of the object is at the start of the delta, check it
+	// before reading the base
+	_, size, err := deltaSizes(data)
+	if err != nil {
+		return object{}, fmt.Errorf("%s at %d: %w", p.path, offset, err)
+	}
+	if maxSize > 0 && size > uint64(maxSize) {
+		return object{}, fmt.Errorf("%s at %d: %w", p.path, offset, errObjectTooLarge)
+	}
+
+	// bases are read whatever their size, the delta may only keep a
+	// small part of them
+	var baseObject object
+	if typ == objOfsDelta {
+		key := fmt.Sprintf("%s@%d", p.path, base.offset)
+		if cached, ok := s.cachedBase(key); ok {
+			baseObject = cached
+		} else if baseObject, err = s.readPacked(p, base.offset, 0, depth+1); err != nil {
+			return object{}, err
+		} else if len(baseObject.data) < maxCachedBaseSize {
+			s.cacheBase(key, baseObject)
+		}
+	} else if baseObject, err = s.readObject(hex.EncodeToString(base.id), 0, depth+1); err != nil {
+		return object{}, err
+	}
+
+	patched, err := applyDelta(baseObject.data, data)
+	if err != nil {
+		return object{}, fmt.Errorf("%s at %d: %w", p.path, offset, err)
+	}
+	return object{typ: baseObject.typ, data: patched}, nil
+}
+
+func (s *objectStore) cachedBase(key string) (object, bool) {
+	s.basesMutex.Lock()
+	defer s.basesMutex.Unlock()
+	o, ok := s.bases[key]
+	return o, ok
+}
+
+func (s *objectStore) cacheBase(key string, o object) {
+	s.basesMutex.Lock()
+	defer s.basesMutex.Unlock()
+	if len(s.bases) >= maxCachedBases {
+		s.bases = make(map[string]object)
+	}
+	s.bases[key] = o
+}
+
+// packfile is a .pack file and its version 2 .idx index
+type packfile struct {
+	path    string
+	pack    *os.File
+	fanout  [256]uint32
+	ids     []byte
+	offsets []byte
+	large   []byte
+}
+
+// deltaBase is the base object of a delta, given by its offset in the same
+// packfile for offset deltas or by its id for ref deltas
+type deltaBase struct {
+	offset int64
+	id     []byte
+}
+
+// openPackfile opens the packfile at path, without its .pack or .idx
+// extension
+func openPackfile(path string) (*packfile, error) {
+	idx, err := os.ReadFile(path + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte("\xfftOc")) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("%s.idx: unsupported pack index version", path)
+	}
+	p := &packfile{path: path}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	n := int(p.fanout[255])
+	idsStart := 8 + 256*4
+	offsetsStart := idsStart + n*20 + n*4
+	largeStart := offsetsStart + n*4
+	if len(idx) < largeStart {
+		return nil, fmt.Errorf("%s.idx: truncated pack index", path)
+	}
+	p.ids = idx[idsStart : idsStart+n*20]
+	p.offsets = idx[offsetsStart:largeStart]
+	p.large = idx[largeStart:]
+
+	if p.pack, err = os.Open(path + ".pack"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *packfile) close() {
+	p.pack.Close()
+}
+
+// find returns the offset of the object with the given id in the packfile
+func (p *packfile) find(id []byte) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.ids[(lo+i)*20:(lo+i+1)*20], id) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.ids[i*20:(i+1)*20], id) {
+		return 0, false
+	}
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	// the offset is an index into the table of 64 bit offsets
+	large := int(offset&0x7fffffff) * 8
+	if large+8 > len(p.large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[large:])), true
+}
+
+// readEntry reads the entry at offset in the packfile. Deltas are returned
+// as is along with their base. Entries whose data is larger than maxSize are
+// not read, for deltas that is the size of the delta itself.
+func (p *packfile) readEntry(offset int64, maxSize int64) (int, []byte, deltaBase, error) {
+	var base deltaBase
+	r := bufio.NewReader(io.NewSectionReader(p.pack, offset, 1<<62))
+
+	// the header holds the type and the inflated size as a varint
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, base, err
+	}
+	typ := int(b>>4) & 7
+	size := int64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, nil, base, err
+		}
+		size |= int64(b&0x7f) << shift
+	}
+	if maxSize > 0 && size > maxSize {
+		return 0, nil, base, fmt.Errorf("%s at %d: %w", p.path, offset, errObjectTooLarge)
+	}
+
+	switch typ {
+	case objOfsDelta:
+		// the distance to the base is a big endian varint where each
+		// continuation adds one
+		if b, err = r.ReadByte(); err != nil {
+			return 0, nil, base, err
+		}
+		distance := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return 0, nil, base, err
+			}
+			distance = ((distance + 1) << 7) | int64(b&0x7f)
+		}
+		base.offset = offset - distance
+	case objRefDelta:
+		base.id = make([]byte, 20)
+		if _, err = io.ReadFull(r, base.id); err != nil {
+			return 0, nil, base, err
+		}
+	case objCommit, objTree, objBlob, objTag:
+	default:
+		return 0, nil, base, fmt.Errorf("%s: unknown object type %d at %d", p.path, typ, offset)
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, base, fmt.Errorf("%s at %d: %w", p.path, offset, err)
+	}
+	defer zr.Close()
+	data, err := readInflated(zr, size)
+	if err != nil {
+		return 0, nil, base, fmt.Errorf("%s at %d: %w", p.path, offset, err)
+	}
+	return typ, data, base, nil
+}
+
+// readInflated reads the size bytes of an object from r. The size comes from
+// the object header and is not trusted, the buffer only grows as data is
+// actually read so a corrupt header cannot make it allocate that much.
+func readInflated(r io.Reader, size int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != size {
+		return nil, fmt.Errorf("object is %d bytes, its header says %d", len(data), size)
+	}
+	return data, nil
+}
+
+// errInvalidDelta is returned for deltas that cannot be applied
+var errInvalidDelta = errors.New("invalid delta")
+
+// deltaSizes returns the sizes of the base and of the object rebuilt by a
+// delta, which start the delta
+func deltaSizes(delta []byte) (uint64, uint64, error) {
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, errInvalidDelta
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, errInvalidDelta
+	}
+	return baseSize, size, nil
+}
+
+// applyDelta rebuilds an object from its base and a delta made of copy
+// and insert instructions
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil || baseSize != uint64(len(base)) {
+		return nil, errInvalidDelta
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, errInvalidDelta
+	}
+
+	// the size is not trusted, only preallocate what a delta of this
+	// length usually rebuilds and check it as the object grows
+	capacity := uint64(len(base) + len(delta))
+	if size < capacity {
+		capacity = size
+	}
+	out := make([]byte, 0, capacity)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		if op&0x80 == 0 {
+			// insert the next op bytes of the delta
+			if op == 0 || int(op) > r.Len() {
+				return nil, errInvalidDelta
+			}
+			if uint64(len(out))+uint64(op) > size {
+				return nil, errInvalidDelta
+			}
+			insert := make([]byte, op)
+			_, _ = r.Read(insert)
+			out = append(out, insert...)
+			continue
+		}
+
+		// copy from the base, the bits of op tell which bytes of the
+		// offset and size follow
+		var copyOffset, copySize uint32
+		for i := uint(0); i < 4; i++ {
+			if op&(1<<i) != 0 {
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, errInvalidDelta
+				}
+				copyOffset |= uint32(b) << (8 * i)
+			}
+		}
+		for i := uint(0); i < 3; i++ {
+			if op&(1<<(4+i)) != 0 {
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, errInvalidDelta
+				}
+				copySize |= uint32(b) << (8 * i)
+			}
+		}
+		if copySize == 0 {
+			copySize = 0x10000
+		}
+		end := uint64(copyOffset) + uint64(copySize)
+		if end > uint64(len(base)) || uint64(len(out))+uint64(copySize) > size {
+			return nil, errInvalidDelta
+		}
+		out = append(out, base[copyOffset:end]...)
+	}
+	if uint64(len(out)) != size {
+		return nil, errInvalidDelta
+	}
+	return out, nil
+}
@@ -0,0 +1,167 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	delta := []byte{
+		11, 20, // base and result sizes
+		0x90, 6, // copy 6 bytes from offset 0
+		9, 'g', 'i', 't', 'l', 'e', 'a', 'k', 's', ' ', // insert 9 bytes
+		0x91, 6, 5, // copy 5 bytes from offset 6
+	}
+	out, err := applyDelta(base, delta)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello gitleaks world", string(out))
+
+	// the delta does not apply to a base of another size
+	_, err = applyDelta([]byte("hello"), delta)
+	assert.Error(t, err)
+
+	// copies past the end of the base are rejected
+	_, err = applyDelta(base, []byte{11, 20, 0x91, 6, 20})
+	assert.Error(t, err)
+}
+
+func TestReadInflatedSize(t *testing.T) {
+	// sizes from object headers are checked against the data read rather
+	// than allocated up front
+	data, err := readInflated(strings.NewReader("abc"), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(data))
+	_, err = readInflated(strings.NewReader("abc"), 1<<40)
+	assert.Error(t, err)
+	_, err = readInflated(strings.NewReader("abcd"), 3)
+	assert.Error(t, err)
+
+	// a delta claiming a huge result is rejected once it rebuilds less
+	_, err = applyDelta([]byte("hello world"), []byte{11, 0x80, 0x80, 0x80, 0x80, 0x10, 0x90, 6})
+	assert.Error(t, err)
+}
+
+// packEntry encodes a packfile entry of type typ, base is the encoded
+// distance or id of the base of deltas
+func packEntry(typ int, base []byte, data []byte) []byte {
+	size := len(data)
+	entry := []byte{byte(typ<<4) | byte(size&0x0f)}
+	for size >>= 4; size > 0; size >>= 7 {
+		entry[len(entry)-1] |= 0x80
+		entry = append(entry, byte(size&0x7f))
+	}
+	entry = append(entry, base...)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+	return append(entry, compressed.Bytes()...)
+}
+
+// deltaSize encodes a size at the start of a delta
+func deltaSize(size int) []byte {
+	var b []byte
+	for ; size >= 0x80; size >>= 7 {
+		b = append(b, byte(size&0x7f)|0x80)
+	}
+	return append(b, byte(size))
+}
+
+// ofsDistance encodes the distance to the base of an offset delta
+func ofsDistance(distance int) []byte {
+	b := []byte{byte(distance & 0x7f)}
+	for distance >>= 7; distance > 0; distance >>= 7 {
+		distance--
+		b = append([]byte{byte(distance&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// writePackfile writes a packfile of the given entries, indexed by the ids
+// of ids that must be sorted, and returns the offsets of the entries
+func writePackfile(t *testing.T, ids []string, entries ...[]byte) (*packfile, []int64) {
+	t.Helper()
+	p := &packfile{path: filepath.Join(t.TempDir(), "pack")}
+	pack := []byte("PACK\x00\x00\x00\x02\x00\x00\x00\x00")
+	var offsets []int64
+	for _, entry := range entries {
+		offsets = append(offsets, int64(len(pack)))
+		pack = append(pack, entry...)
+	}
+	if err := os.WriteFile(p.path+".pack", pack, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i, sha := range ids {
+		id, _ := hex.DecodeString(sha)
+		for b := int(id[0]); b < 256; b++ {
+			p.fanout[b]++
+		}
+		p.ids = append(p.ids, id...)
+		p.offsets = append(p.offsets, 0, 0, 0, byte(offsets[i]))
+	}
+	var err error
+	if p.pack, err = os.Open(p.path + ".pack"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.close)
+	return p, offsets
+}
+
+func TestReadPackedDeltaDepth(t *testing.T) {
+	delta := []byte{1, 1, 0x90, 1}
+
+	// an offset delta whose base is itself
+	p, offsets := writePackfile(t, nil, packEntry(objOfsDelta, ofsDistance(0), delta))
+	s := &objectStore{packs: []*packfile{p}, bases: make(map[string]object)}
+	_, err := s.readPacked(p, offsets[0], 0, 0)
+	assert.ErrorIs(t, err, errDeltaTooDeep)
+
+	// ref deltas that are each other's base
+	a, b := strings.Repeat("01", 20), strings.Repeat("02", 20)
+	aid, _ := hex.DecodeString(a)
+	bid, _ := hex.DecodeString(b)
+	p, _ = writePackfile(t, []string{a, b},
+		packEntry(objRefDelta, bid, delta),
+		packEntry(objRefDelta, aid, delta))
+	s = &objectStore{packs: []*packfile{p}, bases: make(map[string]object)}
+	_, err = s.read(a, 0)
+	assert.ErrorIs(t, err, errDeltaTooDeep)
+
+	// chains of up to maxDeltaDepth deltas are read
+	entries := [][]byte{packEntry(objBlob, nil, []byte("x"))}
+	for i := 0; i < maxDeltaDepth; i++ {
+		entries = append(entries, packEntry(objOfsDelta, ofsDistance(len(entries[i])), delta))
+	}
+	p, offsets = writePackfile(t, nil, entries...)
+	s = &objectStore{packs: []*packfile{p}, bases: make(map[string]object)}
+	o, err := s.readPacked(p, offsets[maxDeltaDepth], 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, object{typ: objBlob, data: []byte("x")}, o)
+	entries = append(entries, packEntry(objOfsDelta, ofsDistance(len(entries[maxDeltaDepth])), delta))
+	p, offsets = writePackfile(t, nil, entries...)
+	s = &objectStore{packs: []*packfile{p}, bases: make(map[string]object)}
+	_, err = s.readPacked(p, offsets[maxDeltaDepth+1], 0, 0)
+	assert.ErrorIs(t, err, errDeltaTooDeep)
+}
+
+func TestReadPackedCachedBases(t *testing.T) {
+	for _, size := range []int{maxCachedBaseSize - 1, maxCachedBaseSize} {
+		base := packEntry(objBlob, nil, bytes.Repeat([]byte("a"), size))
+		delta := append(deltaSize(size), 1, 0x90, 1)
+		p, offsets := writePackfile(t, nil, base, packEntry(objOfsDelta, ofsDistance(len(base)), delta))
+		s := &objectStore{packs: []*packfile{p}, bases: make(map[string]object)}
+		o, err := s.readPacked(p, offsets[1], 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", string(o.data))
+		// only bases below the size cap are kept
+		assert.Equal(t, size < maxCachedBaseSize, len(s.bases) == 1)
+	}
+}
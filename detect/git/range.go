@@ -0,0 +1,128 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LogRange selects the commits of a repo to log by refs, dates and count,
+// e.g. the commits of a pull request with BaseRef set to the target branch
+type LogRange struct {
+	// BaseRef leaves out the commits reachable from it
+	BaseRef string
+
+	// HeadRef is the commit whose history is logged. It defaults to HEAD
+	// if BaseRef is set, if neither is set the history of all refs is
+	// logged like GitLog does.
+	HeadRef string
+
+	// Since and Until leave out commits older or more recent than a
+	// date, in any format git log --since accepts
+	Since string
+	Until string
+
+	// MaxCommits is the number of most recent commits logged, 0 logs
+	// all commits
+	MaxCommits int
+}
+
+// IsZero reports whether r selects no commits, in which case GitLog is used
+func (r LogRange) IsZero() bool {
+	return r == LogRange{}
+}
+
+// logRangeRef is a ref of a LogRange and what it is called in errors
+type logRangeRef struct {
+	name string
+	ref  string
+}
+
+func (r LogRange) refs() []logRangeRef {
+	return []logRangeRef{
+		{"base ref", r.BaseRef},
+		{"head ref", r.HeadRef},
+	}
+}
+
+// check checks what can be checked about r without the repo, so that git
+// log is never run with options in place of refs
+func (r LogRange) check() error {
+	if r.MaxCommits < 0 {
+		return fmt.Errorf("max commits must not be negative, got %d", r.MaxCommits)
+	}
+	for _, ref := range r.refs() {
+		// git would take refs starting with a dash for options
+		if strings.HasPrefix(ref.ref, "-") {
+			return fmt.Errorf("invalid %s %q", ref.name, ref.ref)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the refs of r are commits of the repo at source
+func (r LogRange) Validate(ctx context.Context, source string) error {
+	if err := r.check(); err != nil {
+		return err
+	}
+	for _, ref := range r.refs() {
+		if ref.ref == "" {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, "git", "-C", filepath.Clean(source), "rev-parse",
+			"--verify", "--quiet", ref.ref+"^{commit}")
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%s %q is not a commit of %s", ref.name, ref.ref, source)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// args returns the git log arguments that select the commits of r
+func (r LogRange) args() []string {
+	args := []string{"--full-history"}
+	if r.Since != "" {
+		args = append(args, "--since="+r.Since)
+	}
+	if r.Until != "" {
+		args = append(args, "--until="+r.Until)
+	}
+	if r.MaxCommits > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(r.MaxCommits))
+	}
+	if r.BaseRef == "" && r.HeadRef == "" {
+		args = append(args, "--all")
+	} else {
+		head := r.HeadRef
+		if head == "" {
+			head = "HEAD"
+		}
+		args = append(args, head)
+		if r.BaseRef != "" {
+			args = append(args, "^"+r.BaseRef)
+		}
+	}
+	// end the revisions so a ref is never taken for a path
+	return append(args, "--")
+}
+
+// GitLogRange is like GitLog, but logs the commits selected by r instead of
+// taking log options. The refs of r are not looked up beforehand, git log
+// fails on refs that do not exist. Call Validate first for an error that
+// names the ref.
+func GitLogRange(ctx context.Context, source string, r LogRange, classify StderrClassifier) (*PatchCmd, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	args := append([]string{"-C", filepath.Clean(source), "log", "-p", "-U0"}, r.args()...)
+	return startPatchCmd(exec.CommandContext(ctx, "git", args...), classify)
+}
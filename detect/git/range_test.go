@@ -0,0 +1,41 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogRangeArgs(t *testing.T) {
+	assert.Equal(t, []string{"--full-history", "--all", "--"}, LogRange{}.args())
+	assert.Equal(t,
+		[]string{"--full-history", "--since=2 weeks ago", "--until=2021-11-02", "--max-count=10", "HEAD", "^main", "--"},
+		LogRange{BaseRef: "main", Since: "2 weeks ago", Until: "2021-11-02", MaxCommits: 10}.args())
+	assert.Equal(t, []string{"--full-history", "feature", "--"}, LogRange{HeadRef: "feature"}.args())
+}
+
+func TestSplitLogOpts(t *testing.T) {
+	tests := []struct {
+		logOpts  string
+		expected []string
+	}{
+		{"--all", []string{"--all"}},
+		{"  --all   main..foo ", []string{"--all", "main..foo"}},
+		{`--author="Jane Doe" --since='2 weeks ago'`, []string{"--author=Jane Doe", "--since=2 weeks ago"}},
+		{`--grep="it's" -- "a b.txt"`, []string{"--grep=it's", "--", "a b.txt"}},
+		{`--grep="say \"hi\"" --author=Jane\ Doe`, []string{`--grep=say "hi"`, "--author=Jane Doe"}},
+		{`--grep="a\.b\\c" --grep='a\"b'`, []string{`--grep=a\.b\c`, `--grep=a\"b`}},
+	}
+	for _, tt := range tests {
+		args, err := splitLogOpts(tt.logOpts)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, args, tt.logOpts)
+	}
+
+	_, err := splitLogOpts(`--author="Jane`)
+	assert.Error(t, err)
+	_, err = splitLogOpts(`--author="Jane\"`)
+	assert.Error(t, err)
+	_, err = splitLogOpts(`--author=Jane\`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,285 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Repository reads the history of a git repository as commits and blobs
+// rather than patches, so that content shared by many commits is read once.
+type Repository interface {
+	// Commits calls fn with each commit reachable from the refs of the
+	// repository and HEAD, newest first. Merge commits have no changes
+	// like they do in git log. If fn returns an error it is returned.
+	Commits(ctx context.Context, fn func(Commit) error) error
+
+	// Blobs calls fn with the size and content of each of the given
+	// blobs. content can only be read until fn returns, the part of it
+	// fn does not read is skipped, so large blobs do not have to be held
+	// in memory. Blobs that do not exist or are larger than maxSize bytes
+	// are skipped, a maxSize of 0 skips no blob. If fn returns an error
+	// it is returned.
+	Blobs(ctx context.Context, shas []string, maxSize int64, fn func(sha string, size int64, content io.Reader) error) error
+}
+
+// Commit is a commit and the files it changed
+type Commit struct {
+	SHA     string
+	Author  string
+	Email   string
+	Date    time.Time
+	Message string
+	Changes []Change
+}
+
+// Change is a file added, modified, renamed or copied by a commit
+type Change struct {
+	// Path is the path of the file in the commit
+	Path string
+
+	// OldPath is the path of the file in the parent commit, which
+	// differs from Path if the file was renamed or copied
+	OldPath string
+
+	// OldBlob is the blob of the file in the parent commit, empty if
+	// the file was added
+	OldBlob string
+
+	// NewBlob is the blob of the file in the commit
+	NewBlob string
+}
+
+// cmdRepository is a Repository that runs the git binary
+type cmdRepository struct {
+	source   string
+	classify StderrClassifier
+}
+
+// NewCmdRepository returns a Repository that reads the repository at
+// source by running git. Lines git writes to stderr are classified with
+// classify, DefaultStderrClassifier if nil, and git failing is reported
+// as a *CommandError.
+func NewCmdRepository(source string, classify StderrClassifier) Repository {
+	if classify == nil {
+		classify = DefaultStderrClassifier
+	}
+	return &cmdRepository{source: filepath.Clean(source), classify: classify}
+}
+
+// start starts cmd and reads what it writes to stderr. The returned function
+// waits for cmd to exit and returns a *CommandError if it failed, it must be
+// called once stdout has been read or cmd has been killed.
+func (r *cmdRepository) start(cmd *exec.Cmd) (func() error, error) {
+	log.Debug().Msgf("executing: %s", cmd.String())
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	stderrLines := make(chan string, 1)
+	go func() {
+		stderrLines <- readStderr(stderr, r.classify)
+	}()
+	return func() error {
+		stderr := <-stderrLines
+		return commandError(cmd, stderr, cmd.Wait())
+	}, nil
+}
+
+// commitFormat separates the commits of git log with \x01 and their
+// fields with NUL bytes, the raw diff of a commit follows its fields
+const commitFormat = "--format=%x01%H%x00%an%x00%ae%x00%at%x00%B%x00"
+
+// nullSHA is the blob of a file missing from one side of a raw diff
+const nullSHA = "0000000000000000000000000000000000000000"
+
+func (r *cmdRepository) Commits(ctx context.Context, fn func(Commit) error) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", r.source, "log", "--all", "--full-history",
+		"--raw", "--no-abbrev", "-z", "-M", commitFormat)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	wait, err := r.start(cmd)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewReader(stdout)
+	for {
+		record, readErr := out.ReadString('\x01')
+		if record = strings.TrimSuffix(record, "\x01"); record != "" {
+			commit, err := parseCommit(record)
+			if err == nil {
+				err = fn(commit)
+			}
+			if err != nil {
+				// stop git, its output is no longer read
+				_ = cmd.Process.Kill()
+				_ = wait()
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			_ = cmd.Process.Kill()
+			_ = wait()
+			return readErr
+		}
+	}
+	return wait()
+}
+
+// parseCommit parses a commit written by git log with commitFormat and -z
+func parseCommit(record string) (Commit, error) {
+	fields := strings.SplitN(record, "\x00", 6)
+	if len(fields) < 5 {
+		return Commit{}, fmt.Errorf("unexpected git log output %q", record)
+	}
+	seconds, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return Commit{}, fmt.Errorf("unexpected date in git log output: %w", err)
+	}
+	commit := Commit{
+		SHA:     fields[0],
+		Author:  fields[1],
+		Email:   fields[2],
+		Date:    time.Unix(seconds, 0),
+		Message: strings.TrimSpace(fields[4]),
+	}
+	if len(fields) < 6 {
+		return commit, nil
+	}
+
+	// the raw diff is a list of ":mode mode old new status" entries each
+	// followed by one path, or two paths for renames and copies
+	tokens := strings.Split(strings.TrimLeft(fields[5], "\x00\n"), "\x00")
+	for i := 0; i < len(tokens); i++ {
+		meta := strings.Fields(tokens[i])
+		if len(meta) != 5 || !strings.HasPrefix(meta[0], ":") {
+			continue
+		}
+		newMode, oldBlob, newBlob, status := meta[1], meta[2], meta[3], meta[4]
+		change := Change{OldBlob: oldBlob, NewBlob: newBlob}
+		if oldBlob == nullSHA {
+			change.OldBlob = ""
+		}
+		switch status[0] {
+		case 'R', 'C':
+			if i+2 >= len(tokens) {
+				return commit, fmt.Errorf("unexpected raw diff in git log output of %s", commit.SHA)
+			}
+			change.OldPath, change.Path = tokens[i+1], tokens[i+2]
+			i += 2
+		default:
+			if i+1 >= len(tokens) {
+				return commit, fmt.Errorf("unexpected raw diff in git log output of %s", commit.SHA)
+			}
+			change.OldPath, change.Path = tokens[i+1], tokens[i+1]
+			i++
+		}
+		// skip deleted files and submodules
+		if status[0] == 'D' || newBlob == nullSHA || newMode == "160000" {
+			continue
+		}
+		commit.Changes = append(commit.Changes, change)
+	}
+	return commit, nil
+}
+
+func (r *cmdRepository) Blobs(ctx context.Context, shas []string, maxSize int64,
+	fn func(sha string, size int64, content io.Reader) error) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", r.source, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	wait, err := r.start(cmd)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		w := bufio.NewWriter(stdin)
+		for _, sha := range shas {
+			if _, err := w.WriteString(sha + "\n"); err != nil {
+				break
+			}
+		}
+		w.Flush()
+		stdin.Close()
+	}()
+
+	out := bufio.NewReader(stdout)
+	for range shas {
+		if err = readBlob(out, maxSize, fn); err != nil {
+			break
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// git exited before writing all blobs, report why
+		if waitErr := wait(); waitErr != nil {
+			return waitErr
+		}
+		return err
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = wait()
+		return err
+	}
+	return wait()
+}
+
+// readBlob reads an object written by git cat-file --batch and hands it to
+// fn if it is a blob no larger than maxSize
+func readBlob(out *bufio.Reader, maxSize int64, fn func(sha string, size int64, content io.Reader) error) error {
+	header, err := out.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	// the header is "<sha> <type> <size>" or "<sha> missing"
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return fmt.Errorf("unexpected git cat-file output %q", header)
+	}
+	// the content is followed by a newline
+	if fields[1] != "blob" {
+		_, err = out.Discard(size + 1)
+		return err
+	}
+	if maxSize > 0 && int64(size) > maxSize {
+		log.Debug().Msgf("skipping blob %s, it is larger than %d bytes", fields[0], maxSize)
+		_, err = out.Discard(size + 1)
+		return err
+	}
+	content := &io.LimitedReader{R: out, N: int64(size)}
+	if err = fn(fields[0], int64(size), content); err != nil {
+		return err
+	}
+	// skip what fn did not read and the newline
+	_, err = out.Discard(int(content.N) + 1)
+	return err
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommit(t *testing.T) {
+	record := "491504d5a31946ce75e22554cc34203d8e5ff3ca\x00Zach Rice\x00zricer@protonmail.com\x001635896886\x00" +
+		"adding foo package with secret\n\nand a body\n\x00\x00\n" +
+		":000000 100644 0000000000000000000000000000000000000000 a5caae6d742e49a33982f1fdc608ce861ea59be5 A\x00foo/foo.go\x00" +
+		":100644 100644 d832479114dc6be7207edc7c37ce91dd11b93161 d832479114dc6be7207edc7c37ce91dd11b93161 R100\x00api/api.go\x00api/v2/api.go\x00" +
+		":100644 000000 4839f85db8e7d7e0e6d6f3b7c3f0c52b0b38d6c2 0000000000000000000000000000000000000000 D\x00old.go\x00" +
+		":000000 160000 0000000000000000000000000000000000000000 2e1db472eeba53f06c4026ae4566ea022e36598e A\x00vendor/lib\x00"
+
+	commit, err := parseCommit(record)
+	assert.NoError(t, err)
+	assert.Equal(t, Commit{
+		SHA:     "491504d5a31946ce75e22554cc34203d8e5ff3ca",
+		Author:  "Zach Rice",
+		Email:   "zricer@protonmail.com",
+		Date:    time.Unix(1635896886, 0),
+		Message: "adding foo package with secret\n\nand a body",
+		Changes: []Change{
+			{
+				Path:    "foo/foo.go",
+				OldPath: "foo/foo.go",
+				NewBlob: "a5caae6d742e49a33982f1fdc608ce861ea59be5",
+			},
+			{
+				Path:    "api/v2/api.go",
+				OldPath: "api/api.go",
+				OldBlob: "d832479114dc6be7207edc7c37ce91dd11b93161",
+				NewBlob: "d832479114dc6be7207edc7c37ce91dd11b93161",
+			},
+		},
+	}, commit)
+}
+
+func TestReadBlob(t *testing.T) {
+	batch := "a5caae6d742e49a33982f1fdc608ce861ea59be5 blob 5\nsmall\n" +
+		"d832479114dc6be7207edc7c37ce91dd11b93161 blob 11\nlarger blob\n" +
+		"4839f85db8e7d7e0e6d6f3b7c3f0c52b0b38d6c2 tree 4\ntree\n" +
+		"2e1db472eeba53f06c4026ae4566ea022e36598e missing\n"
+
+	// blobs larger than the max size and other objects are skipped
+	out := bufio.NewReader(strings.NewReader(batch))
+	blobs := make(map[string]string)
+	for i := 0; i < 4; i++ {
+		err := readBlob(out, 10, func(sha string, size int64, content io.Reader) error {
+			b, err := io.ReadAll(content)
+			assert.Equal(t, size, int64(len(b)))
+			blobs[sha] = string(b)
+			return err
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]string{"a5caae6d742e49a33982f1fdc608ce861ea59be5": "small"}, blobs)
+
+	// the part of a blob that is not read is skipped
+	out = bufio.NewReader(strings.NewReader(batch))
+	blobs = make(map[string]string)
+	for i := 0; i < 4; i++ {
+		err := readBlob(out, 0, func(sha string, size int64, content io.Reader) error {
+			b := make([]byte, 3)
+			_, err := io.ReadFull(content, b)
+			blobs[sha] = string(b)
+			return err
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]string{
+		"a5caae6d742e49a33982f1fdc608ce861ea59be5": "sma",
+		"d832479114dc6be7207edc7c37ce91dd11b93161": "lar",
+	}, blobs)
+}
+
+func TestCmdRepositoryError(t *testing.T) {
+	// git failing is reported with its exit code and stderr, so scans can
+	// tell that part of the history was not read
+	repo := NewCmdRepository(t.TempDir(), nil)
+	var cmdErr *CommandError
+	err := repo.Commits(context.Background(), func(Commit) error { return nil })
+	if assert.True(t, errors.As(err, &cmdErr)) {
+		assert.Equal(t, 128, cmdErr.ExitCode)
+		assert.Contains(t, cmdErr.Stderr, "not a git repository")
+	}
+	err = repo.Blobs(context.Background(), []string{nullSHA}, 0, func(string, int64, io.Reader) error { return nil })
+	if assert.True(t, errors.As(err, &cmdErr)) {
+		assert.Equal(t, 128, cmdErr.ExitCode)
+		assert.Contains(t, cmdErr.Stderr, "not a git repository")
+	}
+}
@@ -0,0 +1,110 @@
+package detect
+
+import (
+	"strings"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// gitFragment is a fragment made of lines added by a git diff
+type gitFragment struct {
+	Fragment
+
+	// newPosition is the line in the new version of the file of the
+	// first line of a fragment made of a single hunk
+	newPosition int
+
+	// lineMap maps the lines of a fragment made of several hunks to
+	// lines in the new version of the file
+	lineMap []int
+}
+
+// newLine returns the line in the new version of the file of a line of
+// the fragment
+func (g gitFragment) newLine(line int) int {
+	if line < len(g.lineMap) {
+		return g.lineMap[line]
+	}
+	return line + g.newPosition
+}
+
+// detectGitFile scans the lines added to a file by a git diff and hands each
+// finding to onFinding. The requirements of composite rules are resolved
+// among the findings of all hunks of the file.
+func (d *Detector) detectGitFile(gitdiffFile *gitdiff.File, commitSHA string, onFinding func(report.Finding) error) error {
+	var findings []report.Finding
+	for _, fragment := range d.gitFragments(gitdiffFile, commitSHA) {
+		for _, finding := range d.scanFragment(fragment.Fragment) {
+			if !strings.HasPrefix(finding.Match, "file detected") {
+				finding.StartLine = fragment.newLine(finding.StartLine)
+				finding.EndLine = fragment.newLine(finding.EndLine)
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	allowRegions := gitAllowRegions(gitdiffFile.TextFragments)
+	for _, finding := range d.resolveFindings(findings) {
+		finding = augmentGitFinding(finding, gitdiffFile)
+		if finding.Suppression == nil {
+			// markers may be in other hunks of the file
+			finding.Suppression = regionSuppression(allowRegions, finding.StartLine, finding.EndLine+1, finding.RuleID)
+		}
+		if err := onFinding(finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gitFragments returns the fragments to scan for a file of a git diff. Each
+// hunk is a fragment of its own, unless GroupHunks is set in which case all
+// hunks are joined in a single fragment.
+func (d *Detector) gitFragments(gitdiffFile *gitdiff.File, commitSHA string) []gitFragment {
+	var fragments []gitFragment
+	for _, textFragment := range gitdiffFile.TextFragments {
+		if textFragment == nil {
+			break
+		}
+		fragments = append(fragments, gitFragment{
+			Fragment: Fragment{
+				Raw:       textFragment.Raw(gitdiff.OpAdd),
+				CommitSHA: commitSHA,
+				FilePath:  gitdiffFile.NewName,
+			},
+			newPosition: int(textFragment.NewPosition),
+		})
+	}
+	if !d.GroupHunks || len(fragments) < 2 {
+		return fragments
+	}
+
+	// hunks of a diff with no context lines only hold the lines that
+	// were added, so each hunk is a run of consecutive lines
+	var (
+		sb      strings.Builder
+		lineMap []int
+	)
+	for _, fragment := range fragments {
+		raw := fragment.Raw
+		if raw != "" && !strings.HasSuffix(raw, "\n") {
+			raw += "\n"
+		}
+		for i := 0; i < strings.Count(raw, "\n"); i++ {
+			lineMap = append(lineMap, fragment.newPosition+i)
+		}
+		sb.WriteString(raw)
+	}
+	return []gitFragment{
+		{
+			Fragment: Fragment{
+				Raw:       sb.String(),
+				CommitSHA: commitSHA,
+				FilePath:  gitdiffFile.NewName,
+			},
+			lineMap: lineMap,
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package detect
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// gitleaksIgnoreFileName is the name of the file containing finding
+// fingerprints that should be ignored. By default it is looked up in the
+// root of the source being scanned.
+const gitleaksIgnoreFileName = ".gitleaksignore"
+
+// loadGitleaksIgnore reads a .gitleaksignore file and returns the set of
+// fingerprints it contains. Blank lines and lines starting with # are skipped.
+func loadGitleaksIgnore(gitleaksIgnorePath string) (map[string]bool, error) {
+	file, err := os.Open(gitleaksIgnorePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fingerprints := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fingerprints[line] = true
+	}
+	return fingerprints, scanner.Err()
+}
+
+// isIgnored returns true if the finding's fingerprint is listed in the
+// .gitleaksignore file and keeps count of how many findings were ignored.
+func (d *Detector) isIgnored(finding report.Finding) bool {
+	if !d.gitleaksIgnore[finding.Fingerprint] {
+		return false
+	}
+	d.findingMutex.Lock()
+	d.ignoredCount++
+	d.findingMutex.Unlock()
+	return true
+}
@@ -0,0 +1,90 @@
+package detect
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+const gitleaksIgnorePath = "../testdata/gitleaksignore/"
+
+func TestLoadGitleaksIgnore(t *testing.T) {
+	fingerprints, err := loadGitleaksIgnore(filepath.Join(gitleaksIgnorePath, ".gitleaksignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]bool{
+		"../testdata/repos/nogit/main.go:aws-access-key:a833f87ec2d3eac3":       true,
+		"1b6da43b82b22e4eaa10bcf8ee591e91abbfc587:main.go:aws-access-key:20:19": true,
+	}, fingerprints)
+
+	_, err = loadGitleaksIgnore(filepath.Join(gitleaksIgnorePath, "does_not_exist"))
+	assert.Error(t, err)
+}
+
+func TestDetectWithGitleaksIgnore(t *testing.T) {
+	err := moveDotGit("dotGit", ".git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := moveDotGit(".git", "dotGit"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	viper.Reset()
+	viper.AddConfigPath(configPath)
+	viper.SetConfigName("simple")
+	viper.SetConfigType("toml")
+	err = viper.ReadInConfig()
+	if err != nil {
+		t.Error(err)
+	}
+	var vc config.ViperConfig
+	err = viper.Unmarshal(&vc)
+	if err != nil {
+		t.Error(err)
+	}
+	cfg, _ := vc.Translate()
+
+	detector := NewDetector(cfg)
+	if err = detector.AddGitleaksIgnore(gitleaksIgnorePath); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := detector.DetectFiles(context.Background(), filepath.Join(repoBasePath, "nogit"))
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Len(t, findings, 0)
+	assert.Equal(t, 1, detector.ignoredCount)
+
+	detector = NewDetector(cfg)
+	if err = detector.AddGitleaksIgnore(gitleaksIgnorePath); err != nil {
+		t.Fatal(err)
+	}
+	findings, err = detector.DetectGit(context.Background(), filepath.Join(repoBasePath, "small"), "", DetectType)
+	if err != nil {
+		t.Error(err)
+	}
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, "491504d5a31946ce75e22554cc34203d8e5ff3ca:foo/foo.go:aws-access-key:9:17", findings[0].Fingerprint)
+	}
+	assert.Equal(t, 1, detector.ignoredCount)
+}
+
+func TestGitFingerprintColumn(t *testing.T) {
+	// findings of a rule on the same line of a commit are ignored one by one
+	first := report.Finding{Commit: "1b6da43b82b22e4eaa10bcf8ee591e91abbfc587", File: "main.go",
+		RuleID: "aws-access-key", StartLine: 20, StartColumn: 19}
+	second := first
+	second.StartColumn = 45
+	assert.Equal(t, "1b6da43b82b22e4eaa10bcf8ee591e91abbfc587:main.go:aws-access-key:20:19", gitFingerprint(first))
+	assert.NotEqual(t, gitFingerprint(first), gitFingerprint(second))
+}
@@ -0,0 +1,115 @@
+package detect
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// headLookup is whether a secret is in the tree of the default branch. The
+// same secret is often found in many commits, so it is only looked up once
+// and shared by all of its findings.
+type headLookup struct {
+	once    sync.Once
+	present bool
+	err     error
+}
+
+// absentFinding is a finding whose secret is no longer on the default branch.
+// It is held back until the commits that removed secrets have been looked
+// up, see reportAbsent.
+type absentFinding struct {
+	// secret is the secret of the finding, which may have been redacted
+	secret  string
+	finding report.Finding
+}
+
+// checkHead records on a finding whether its secret is still in the tree of
+// the default branch of the repo at source
+func (d *Detector) checkHead(ctx context.Context, source string, finding *report.Finding) {
+	if finding.Secret == "" {
+		return
+	}
+
+	d.headRefOnce.Do(func() {
+		d.headRef, d.headRefErr = git.DefaultBranch(ctx, source)
+	})
+	if d.headRefErr != nil {
+		log.Debug().Err(d.headRefErr).Msgf("unable to resolve the default branch of %s", source)
+		return
+	}
+
+	cached, _ := d.headLookups.LoadOrStore(finding.Secret, &headLookup{})
+	lookup := cached.(*headLookup)
+	lookup.once.Do(func() {
+		lookup.present, lookup.err = git.HeadContains(ctx, source, d.headRef, finding.Secret)
+	})
+	if lookup.err != nil {
+		log.Debug().Err(lookup.err).Msgf("unable to check %s for %s finding in %s", d.headRef, finding.RuleID, finding.File)
+		return
+	}
+
+	if lookup.present {
+		finding.HeadStatus = report.HeadPresent
+	} else {
+		finding.HeadStatus = report.HeadAbsent
+	}
+}
+
+// holdAbsent returns an onFinding callback that holds back the findings whose
+// secret is not on the default branch and hands the others to onFinding.
+// secret is the secret of the finding before it was redacted. Like
+// onFinding, it is called while holding findingMutex.
+func (d *Detector) holdAbsent(secret string, onFinding func(report.Finding) error) func(report.Finding) error {
+	return func(finding report.Finding) error {
+		if finding.HeadStatus != report.HeadAbsent {
+			return onFinding(finding)
+		}
+		d.absentFindings = append(d.absentFindings, absentFinding{secret: secret, finding: finding})
+		return nil
+	}
+}
+
+// reportAbsent looks up the commits that removed the secrets of the findings
+// held back by holdAbsent from their files, in one walk of the history of the
+// default branch of the repo at source, and hands the findings to onFinding.
+// If the scan was stopped the findings are handed over without that commit.
+func (d *Detector) reportAbsent(ctx context.Context, source string, onFinding func(report.Finding) error) error {
+	absent := d.absentFindings
+	d.absentFindings = nil
+	if len(absent) == 0 {
+		return nil
+	}
+
+	var removals *git.Removals
+	if ctx.Err() == nil {
+		var secrets []string
+		seen := make(map[string]bool)
+		for _, a := range absent {
+			if !seen[a.secret] {
+				seen[a.secret] = true
+				secrets = append(secrets, a.secret)
+			}
+		}
+		var err error
+		if removals, err = git.RemovedIn(ctx, source, d.headRef, secrets, d.GitStderrClassifier); err != nil {
+			log.Debug().Err(err).Msgf("unable to look up the commits that removed secrets from %s", d.headRef)
+		}
+	}
+
+	d.findingMutex.Lock()
+	defer d.findingMutex.Unlock()
+	for _, a := range absent {
+		if removals != nil {
+			a.finding.RemovedIn = removals.Commit(a.secret, a.finding.File)
+		}
+		if err := onFinding(a.finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package detect
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+	"github.com/zricethezav/gitleaks/v8/detect/git"
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+func TestDetectGitCheckHead(t *testing.T) {
+	err := moveDotGit("dotGit", ".git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := moveDotGit(".git", "dotGit"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	viper.AddConfigPath(configPath)
+	viper.SetConfigName("simple")
+	viper.SetConfigType("toml")
+	if err = viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	var vc config.ViperConfig
+	if err = viper.Unmarshal(&vc); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := vc.Translate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detector := NewDetector(cfg)
+	detector.CheckHead = true
+	detector.Redact = true
+	source := filepath.Join(repoBasePath, "small")
+	findings, err := detector.DetectGit(context.Background(), source, "", DetectType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removedIn := make(map[string]string)
+	for _, f := range findings {
+		// the secret is looked up before it is redacted
+		assert.Equal(t, report.HeadAbsent, f.HeadStatus)
+		assert.Equal(t, "REDACT", f.Secret)
+		removedIn[f.File] = f.RemovedIn
+	}
+	assert.Equal(t, map[string]string{
+		// removed on the main branch
+		"main.go": "906335481df9a4b48906c90318b4fac76b67fe73",
+		// only ever on the foo branch, which is not merged into HEAD
+		"foo/foo.go": "",
+	}, removedIn)
+
+	// findings are checked against the branch origin/HEAD points to
+	ref, err := git.DefaultBranch(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/remotes/origin/main", ref)
+
+	// lines of a multi-line secret only need to be in the same file
+	present, err := git.HeadContains(context.Background(), source, "HEAD", "package main\n\tawsToken := os.Getenv(\"AWS_TOKEN\")")
+	assert.NoError(t, err)
+	assert.True(t, present)
+	present, err = git.HeadContains(context.Background(), source, "HEAD", "package main\nAKIALALEMEL33243OLIA")
+	assert.NoError(t, err)
+	assert.False(t, present)
+}
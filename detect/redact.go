@@ -0,0 +1,99 @@
+package detect
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DefaultRedactMask replaces secrets in the output of RedactStream
+const DefaultRedactMask = "REDACTED"
+
+// RedactStream copies r to w, replacing each secret found in it with mask.
+// Only the secret group of a match is replaced, the rest of the match is kept.
+// Secrets in encoded blobs are masked by replacing the whole blob. The content
+// is processed one line at a time and every line is written as soon as it has
+// been scanned so RedactStream can be used in a live pipe. As a consequence,
+// secrets spanning several lines, like private keys, are not redacted.
+// path is the file name used to match path based rules and allowlists.
+func (d *Detector) RedactStream(r io.Reader, w io.Writer, path string, mask string) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if line != "" {
+			if _, werr := io.WriteString(w, d.redactLine(line, path, mask)); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// redactLine returns line with the secrets found in it replaced by mask
+func (d *Detector) redactLine(line string, path string, mask string) string {
+	if d.pathAllowed(path) {
+		return line
+	}
+
+	// scan the line without its line ending so matches are located on
+	// the first line of the fragment
+	content := strings.TrimRight(line, "\n")
+	fragment := Fragment{
+		Raw:      content,
+		FilePath: path,
+	}
+
+	var spans [][2]int
+	for _, finding := range d.detect(fragment, nil) {
+		if finding.Suppression != nil {
+			// allowed by a gitleaks:allow comment
+			continue
+		}
+		if isDecoded(finding) {
+			// location() columns are 1-indexed and inclusive for
+			// a fragment without newlines
+			spans = append(spans, [2]int{finding.StartColumn - 1, finding.EndColumn})
+			continue
+		}
+		if finding.Secret == "" {
+			continue
+		}
+		for i := 0; i < len(content); {
+			j := strings.Index(content[i:], finding.Secret)
+			if j < 0 {
+				break
+			}
+			spans = append(spans, [2]int{i + j, i + j + len(finding.Secret)})
+			i += j + len(finding.Secret)
+		}
+	}
+	if len(spans) == 0 {
+		return line
+	}
+
+	// merge overlapping spans so every secret is masked exactly once
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+	var sb strings.Builder
+	end := 0
+	for _, span := range spans {
+		if span[1] <= end {
+			continue
+		}
+		if span[0] >= end {
+			sb.WriteString(content[end:span[0]])
+			sb.WriteString(mask)
+		}
+		end = span[1]
+	}
+	sb.WriteString(line[end:])
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package detect
+
+import (
+	"context"
+
+	"github.com/fatih/semgroup"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+)
+
+// findingStream wraps the onFinding callback given to the streaming detect
+// functions. The first error returned by the callback cancels the scan and is
+// reported instead of the errors caused by the cancellation.
+type findingStream struct {
+	onFinding func(report.Finding) error
+	cancel    context.CancelFunc
+
+	// err is the first error returned by onFinding and sent is the number
+	// of findings handed to it. send is always called while holding the
+	// detector's findingMutex so no extra locking is needed.
+	err  error
+	sent int
+}
+
+func newFindingStream(onFinding func(report.Finding) error, cancel context.CancelFunc) *findingStream {
+	return &findingStream{
+		onFinding: onFinding,
+		cancel:    cancel,
+	}
+}
+
+// send hands a finding to the callback unless a previous call failed
+func (fs *findingStream) send(finding report.Finding) error {
+	if fs.err != nil {
+		return fs.err
+	}
+	if err := fs.onFinding(finding); err != nil {
+		fs.err = err
+		fs.cancel()
+		return err
+	}
+	fs.sent++
+	return nil
+}
+
+// wait waits for all scan workers to finish and returns the callback error
+// if there was one, ctx.Err() if the scan was cancelled, otherwise the errors
+// collected by the group.
+func (fs *findingStream) wait(ctx context.Context, s *semgroup.Group) error {
+	err := s.Wait()
+	if fs.err != nil {
+		return fs.err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -1,6 +1,8 @@
 package detect
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -13,14 +15,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// augmentGitFinding updates the start and end line numbers of a finding to include the
-// delta from the git diff
-func augmentGitFinding(finding report.Finding, textFragment *gitdiff.TextFragment, f *gitdiff.File) report.Finding {
-	if !strings.HasPrefix(finding.Match, "file detected") {
-		finding.StartLine += int(textFragment.NewPosition)
-		finding.EndLine += int(textFragment.NewPosition)
-	}
-
+// augmentGitFinding adds the commit information of a git diff to a finding.
+// The line numbers of the finding must already be relative to the file.
+func augmentGitFinding(finding report.Finding, f *gitdiff.File) report.Finding {
 	if f.PatchHeader != nil {
 		finding.Commit = f.PatchHeader.SHA
 		finding.Message = f.PatchHeader.Message()
@@ -29,10 +26,27 @@ func augmentGitFinding(finding report.Finding, textFragment *gitdiff.TextFragmen
 			finding.Email = f.PatchHeader.Author.Email
 		}
 		finding.Date = f.PatchHeader.AuthorDate.UTC().Format(time.RFC3339)
+		finding.Fingerprint = gitFingerprint(finding)
 	}
 	return finding
 }
 
+// fingerprint returns a stable identifier for a finding that is not tied to
+// a commit. The secret is hashed so that the fingerprint does not depend on
+// the line the secret is on and does not leak the secret itself.
+func fingerprint(finding report.Finding) string {
+	h := sha256.Sum256([]byte(finding.Secret))
+	return fmt.Sprintf("%s:%s:%s", finding.File, finding.RuleID, hex.EncodeToString(h[:8]))
+}
+
+// gitFingerprint returns a stable identifier for a finding introduced
+// by a commit. Line and column numbers are stable for a given commit and
+// file, the column tells apart findings of a rule on the same line.
+func gitFingerprint(finding report.Finding) string {
+	return fmt.Sprintf("%s:%s:%s:%d:%d", finding.Commit, finding.File, finding.RuleID, finding.StartLine,
+		finding.StartColumn)
+}
+
 // shannonEntropy calculates the entropy of data using the formula defined here:
 // https://en.wiktionary.org/wiki/Shannon_entropy
 // Another way to think about what this is doing is calculating the number of bits
@@ -57,8 +71,8 @@ func shannonEntropy(data string) (entropy float64) {
 	return entropy
 }
 
-// filter will dedupe and redact findings
-func filter(findings []report.Finding, redact bool) []report.Finding {
+// filter will dedupe findings
+func filter(findings []report.Finding) []report.Finding {
 	var retFindings []report.Finding
 	for _, f := range findings {
 		include := true
@@ -80,9 +94,6 @@ func filter(findings []report.Finding, redact bool) []report.Finding {
 			}
 		}
 
-		if redact {
-			f.Redact()
-		}
 		if include {
 			retFindings = append(retFindings, f)
 		}
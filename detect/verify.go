@@ -0,0 +1,49 @@
+package detect
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/zricethezav/gitleaks/v8/report"
+	"github.com/zricethezav/gitleaks/v8/verify"
+)
+
+// verifyTimeout bounds the time spent verifying a single secret
+const verifyTimeout = 10 * time.Second
+
+// verify checks secret, the secret of a finding before it was redacted, with
+// the verifier of the rule of the finding, if there is one, and records the
+// result on the finding. Verification stops when ctx is cancelled.
+func (d *Detector) verify(ctx context.Context, finding *report.Finding, secret string) {
+	verifier, ok := d.Verifiers[finding.RuleID]
+	if !ok || secret == "" {
+		return
+	}
+
+	// the same secret is often found in many commits, only check it once,
+	// including when checking it failed so a provider that does not answer
+	// is not waited on again for every finding of the secret
+	key := finding.RuleID + ":" + secret
+	if cached, ok := d.verified.Load(key); ok {
+		status := cached.(verify.Status)
+		finding.Status = string(status)
+		finding.Verified = status == verify.Verified
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	status, err := verifier.Verify(ctx, secret)
+	if err != nil {
+		log.Debug().Err(err).Msgf("unable to verify %s finding in %s", finding.RuleID, finding.File)
+	}
+	// a scan being stopped says nothing about the secret
+	if ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		d.verified.Store(key, status)
+	}
+	finding.Status = string(status)
+	finding.Verified = status == verify.Verified
+}
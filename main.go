@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 
@@ -14,15 +15,20 @@ func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
 	// this block sets up a go routine to listen for an interrupt signal
-	// which will immediately exit gitleaks
+	// which will stop the scan. Findings gathered so far are still
+	// written to the report.
+	ctx, cancel := context.WithCancel(context.Background())
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt)
-	go listenForInterrupt(stopChan)
+	go listenForInterrupt(stopChan, cancel)
 
-	cmd.Execute()
+	cmd.Execute(ctx)
 }
 
-func listenForInterrupt(stopScan chan os.Signal) {
+func listenForInterrupt(stopScan chan os.Signal, cancel context.CancelFunc) {
 	<-stopScan
-	log.Fatal().Msg("Interrupt signal received. Exiting...")
+	log.Warn().Msg("Interrupt signal received. Stopping scan and writing partial report, interrupt again to exit immediately...")
+	// restore the default behavior so a second interrupt exits immediately
+	signal.Stop(stopScan)
+	cancel()
 }
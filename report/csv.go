@@ -4,16 +4,12 @@ import (
 	"encoding/csv"
 	"io"
 	"strconv"
+	"strings"
 )
 
-// writeCsv writes the list of findings to a writeCloser.
-func writeCsv(f []Finding, w io.WriteCloser) error {
-	if len(f) == 0 {
-		return nil
-	}
-	defer w.Close()
-	cw := csv.NewWriter(w)
-	err := cw.Write([]string{"RuleID",
+// csvHeader returns the header row of a csv report
+func csvHeader(opts Options) []string {
+	header := []string{"RuleID",
 		"Commit",
 		"File",
 		"Secret",
@@ -26,30 +22,173 @@ func writeCsv(f []Finding, w io.WriteCloser) error {
 		"Message",
 		"Date",
 		"Email",
-	})
+		"Fingerprint",
+	}
+	if opts.Verify {
+		header = append(header, "Verified", "Status")
+	}
+	if opts.CheckHead {
+		header = append(header, "HeadStatus", "RemovedIn")
+	}
+	return append(header, "OccurrenceCount",
+		"CommitCount",
+		"FileCount",
+		"Occurrences",
+		"Suppressed",
+		"SuppressionReason",
+		"SuppressionUntil",
+	)
+}
+
+// csvRecord returns the csv row for a finding
+func csvRecord(f Finding, opts Options) []string {
+	record := []string{f.RuleID,
+		f.Commit,
+		f.File,
+		f.Secret,
+		f.Match,
+		strconv.Itoa(f.StartLine),
+		strconv.Itoa(f.EndLine),
+		strconv.Itoa(f.StartColumn),
+		strconv.Itoa(f.EndColumn),
+		f.Author,
+		f.Message,
+		f.Date,
+		f.Email,
+		f.Fingerprint,
+	}
+	if opts.Verify {
+		record = append(record, strconv.FormatBool(f.Verified), f.Status)
+	}
+	if opts.CheckHead {
+		record = append(record, f.HeadStatus, f.RemovedIn)
+	}
+	return append(record, csvCount(f.OccurrenceCount),
+		csvCount(f.CommitCount),
+		csvCount(f.FileCount),
+		csvOccurrences(f.Occurrences),
+		strconv.FormatBool(f.Suppression != nil),
+		csvSuppression(f.Suppression).Reason,
+		csvSuppression(f.Suppression).Until,
+	)
+}
+
+// csvSuppression returns the suppression of a finding, or an empty one for
+// findings that were not suppressed
+func csvSuppression(s *Suppression) Suppression {
+	if s == nil {
+		return Suppression{}
+	}
+	return *s
+}
+
+// csvCount formats the count of a grouped finding, counts of findings that
+// are not grouped are left empty
+func csvCount(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// csvOccurrences formats the occurrences of a grouped finding as a list of
+// commit:file:line, or file:line outside of git, separated by semicolons
+func csvOccurrences(occurrences []Occurrence) string {
+	s := make([]string, 0, len(occurrences))
+	for _, o := range occurrences {
+		location := o.File + ":" + strconv.Itoa(o.StartLine)
+		if o.Commit != "" {
+			location = o.Commit + ":" + location
+		}
+		s = append(s, location)
+	}
+	return strings.Join(s, ";")
+}
+
+// csvOptions returns the options of a csv report of findings that are all
+// known up front, optional columns are added if any finding has them set
+func csvOptions(findings []Finding) Options {
+	var opts Options
+	for _, f := range findings {
+		opts.Verify = opts.Verify || f.Status != ""
+		opts.CheckHead = opts.CheckHead || f.HeadStatus != ""
+	}
+	return opts
+}
+
+// writeCsv writes the list of findings to a writeCloser. Suppressed findings
+// are written after the other findings.
+func writeCsv(f []Finding, w io.WriteCloser) error {
+	if len(f) == 0 {
+		return nil
+	}
+	defer w.Close()
+	opts := csvOptions(f)
+	leaks, suppressed := splitSuppressed(f)
+	f = append(leaks, suppressed...)
+	cw := csv.NewWriter(w)
+	err := cw.Write(csvHeader(opts))
 	if err != nil {
 		return err
 	}
 	for _, f := range f {
-		err = cw.Write([]string{f.RuleID,
-			f.Commit,
-			f.File,
-			f.Secret,
-			f.Match,
-			strconv.Itoa(f.StartLine),
-			strconv.Itoa(f.EndLine),
-			strconv.Itoa(f.StartColumn),
-			strconv.Itoa(f.EndColumn),
-			f.Author,
-			f.Message,
-			f.Date,
-			f.Email,
-		})
+		err = cw.Write(csvRecord(f, opts))
 		if err != nil {
 			return err
 		}
 	}
-
 	cw.Flush()
 	return cw.Error()
 }
+
+// csvStreamWriter writes findings to a csv report as they are found.
+// Like writeCsv, nothing is written if there are no findings, and
+// suppressed findings are kept until Close to be written after the others.
+type csvStreamWriter struct {
+	w          io.WriteCloser
+	cw         *csv.Writer
+	opts       Options
+	written    bool
+	suppressed []Finding
+}
+
+func (c *csvStreamWriter) WriteFinding(f Finding) error {
+	if f.Suppression != nil {
+		c.suppressed = append(c.suppressed, f)
+		return nil
+	}
+	return c.writeRecord(f)
+}
+
+func (c *csvStreamWriter) writeRecord(f Finding) error {
+	if !c.written {
+		if err := c.cw.Write(csvHeader(c.opts)); err != nil {
+			return err
+		}
+		c.written = true
+	}
+	if err := c.cw.Write(csvRecord(f, c.opts)); err != nil {
+		return err
+	}
+	// flush every record so the report on disk is always up to date
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+// MarkIncomplete is a no-op, csv reports have no place for report metadata
+func (c *csvStreamWriter) MarkIncomplete() {}
+
+func (c *csvStreamWriter) Close() error {
+	for _, f := range c.suppressed {
+		if err := c.writeRecord(f); err != nil {
+			c.w.Close()
+			return err
+		}
+	}
+	c.cw.Flush()
+	if err := c.cw.Error(); err != nil {
+		c.w.Close()
+		return err
+	}
+	return c.w.Close()
+}
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestWriteCSV(t *testing.T) {
@@ -32,6 +34,7 @@ func TestWriteCSV(t *testing.T) {
 					Author:      "John Doe",
 					Email:       "johndoe@gmail.com",
 					Date:        "10-19-2003",
+					Fingerprint: "0000000000000000:auth.py:test-rule:1",
 				},
 			}},
 		{
@@ -82,3 +85,29 @@ func TestWriteCSV(t *testing.T) {
 		os.Remove(tmpfile.Name())
 	}
 }
+
+func TestCsvOptionalColumns(t *testing.T) {
+	f := Finding{RuleID: "test-rule", File: "auth.py", Verified: true, Status: "verified",
+		HeadStatus: HeadAbsent, RemovedIn: "1111111111111111"}
+
+	// the verification and head columns are only added if the scan
+	// verified secrets or checked the default branch
+	assert.NotContains(t, csvHeader(Options{}), "Status")
+	assert.NotContains(t, csvHeader(Options{}), "HeadStatus")
+	assert.Len(t, csvRecord(f, Options{}), len(csvHeader(Options{})))
+	opts := Options{Verify: true, CheckHead: true}
+	header, record := csvHeader(opts), csvRecord(f, opts)
+	assert.Len(t, record, len(header))
+	columns := make(map[string]string)
+	for i, column := range header {
+		columns[column] = record[i]
+	}
+	assert.Equal(t, "true", columns["Verified"])
+	assert.Equal(t, "verified", columns["Status"])
+	assert.Equal(t, HeadAbsent, columns["HeadStatus"])
+	assert.Equal(t, "1111111111111111", columns["RemovedIn"])
+
+	// reports of findings known up front add them if any finding has them
+	assert.Equal(t, opts, csvOptions([]Finding{{}, f}))
+	assert.Equal(t, Options{}, csvOptions([]Finding{{}}))
+}
@@ -1,6 +1,8 @@
 package report
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 )
 
@@ -35,10 +37,96 @@ type Finding struct {
 
 	// Rule is the name of the rule that was matched
 	RuleID string
+
+	// Fingerprint is a stable identifier for this finding. For git scans
+	// it is made up of commit:file:rule:line, otherwise it is made up of
+	// file:rule:secret-hash.
+	Fingerprint string
+
+	// Verified is true if the secret was checked with the provider
+	// and found to be live.
+	Verified bool `json:",omitempty"`
+
+	// Status is the result of verifying the secret: verified, invalid
+	// or unknown. It is empty if the secret was not verified.
+	Status string `json:",omitempty"`
+
+	// HeadStatus is the result of checking whether the secret of a git
+	// finding is still in the tree of the default branch: present or
+	// absent. It is empty if the default branch was not checked.
+	HeadStatus string `json:",omitempty"`
+
+	// RemovedIn is the last commit on the default branch's history that
+	// removed the secret from File. It is empty if the secret is still
+	// present or never reached the default branch's history.
+	RemovedIn string `json:",omitempty"`
+
+	// RequiredFindings are the matches of the rules required by a
+	// composite rule, e.g. the secret access key that goes with an
+	// access key ID.
+	RequiredFindings []RequiredFinding `json:",omitempty"`
+
+	// Suppression is set if the finding was suppressed by an inline
+	// gitleaks:allow comment. Suppressed findings are not reported as
+	// leaks, reports list them apart from the other findings for audits.
+	Suppression *Suppression `json:",omitempty"`
+
+	// Occurrences lists every place the secret was found when findings
+	// are grouped by secret with GroupBySecret. The grouped finding
+	// itself describes the first commit that introduced the secret.
+	Occurrences []Occurrence `json:",omitempty"`
+
+	// OccurrenceCount, CommitCount and FileCount are the number of
+	// occurrences of a grouped finding and of the distinct commits and
+	// files they are in.
+	OccurrenceCount int `json:",omitempty"`
+	CommitCount     int `json:",omitempty"`
+	FileCount       int `json:",omitempty"`
+
+	// secretHash identifies the secret of a redacted finding so it can
+	// still be grouped by secret
+	secretHash string
+}
+
+// Values of Finding.HeadStatus
+const (
+	HeadPresent = "present"
+	HeadAbsent  = "absent"
+)
+
+// Suppression records why a finding was suppressed
+type Suppression struct {
+	// Reason is the reason= given in the gitleaks:allow comment
+	Reason string `json:",omitempty"`
+
+	// Until is the until= date of the gitleaks:allow comment
+	Until string `json:",omitempty"`
+}
+
+// RequiredFinding is the match of a rule required by a composite rule
+type RequiredFinding struct {
+	RuleID string
+	Match  string
+	Secret string
 }
 
 // Redact removes sensitive information from a finding.
 func (f *Finding) Redact() {
+	f.secretHash = f.secretKey()
 	f.Match = strings.Replace(f.Match, f.Secret, "REDACTED", -1)
 	f.Secret = "REDACT"
+	for i := range f.RequiredFindings {
+		rf := &f.RequiredFindings[i]
+		rf.Match = strings.Replace(rf.Match, rf.Secret, "REDACTED", -1)
+		rf.Secret = "REDACT"
+	}
+}
+
+// secretKey identifies the secret of a finding, whether it is redacted or not
+func (f *Finding) secretKey() string {
+	if f.secretHash != "" {
+		return f.secretHash
+	}
+	h := sha256.Sum256([]byte(f.Secret))
+	return hex.EncodeToString(h[:])
 }
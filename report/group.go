@@ -0,0 +1,132 @@
+package report
+
+import (
+	"sort"
+)
+
+// Occurrence is a place a secret was found, listed on the finding of a
+// group of findings sharing a rule and secret
+type Occurrence struct {
+	Commit      string
+	File        string
+	StartLine   int
+	EndLine     int
+	StartColumn int
+	EndColumn   int
+	Author      string
+	Email       string
+	Date        string
+	Fingerprint string
+}
+
+// GroupBySecret collapses findings that share a rule and secret into one
+// finding for the first commit that introduced the secret. The grouped
+// finding lists every occurrence and counts the distinct commits and files
+// they are in. Groups are returned in the order their first finding appears
+// in findings.
+func GroupBySecret(findings []Finding) []Finding {
+	var (
+		keys   []string
+		groups = make(map[string][]Finding)
+	)
+	for _, f := range findings {
+		key := groupKey(f)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+	}
+
+	grouped := make([]Finding, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		// dates are RFC3339 in UTC so they sort as strings, findings
+		// without a date keep the order they were found in
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Date < group[j].Date
+		})
+
+		first := group[0]
+		first.Occurrences = make([]Occurrence, 0, len(group))
+		commits := make(map[string]bool)
+		files := make(map[string]bool)
+		for _, f := range group {
+			first.Occurrences = append(first.Occurrences, Occurrence{
+				Commit:      f.Commit,
+				File:        f.File,
+				StartLine:   f.StartLine,
+				EndLine:     f.EndLine,
+				StartColumn: f.StartColumn,
+				EndColumn:   f.EndColumn,
+				Author:      f.Author,
+				Email:       f.Email,
+				Date:        f.Date,
+				Fingerprint: f.Fingerprint,
+			})
+			commits[f.Commit] = true
+			files[f.File] = true
+		}
+		first.OccurrenceCount = len(group)
+		first.CommitCount = len(commits)
+		first.FileCount = len(files)
+		grouped = append(grouped, first)
+	}
+	return grouped
+}
+
+// groupKey identifies the group of a finding, findings share a group if they
+// share a rule and secret
+func groupKey(f Finding) string {
+	return f.RuleID + "\x00" + f.secretKey()
+}
+
+// GroupCounter counts the groups GroupBySecret makes of the findings added to
+// it without keeping the findings
+type GroupCounter struct {
+	keys map[string]bool
+}
+
+// Add adds a finding to its group and returns the number of groups so far
+func (c *GroupCounter) Add(f Finding) int {
+	if c.keys == nil {
+		c.keys = make(map[string]bool)
+	}
+	c.keys[groupKey(f)] = true
+	return len(c.keys)
+}
+
+// NewGroupingStreamWriter returns a StreamWriter that groups findings by
+// secret, see GroupBySecret, and writes the groups to w on Close.
+func NewGroupingStreamWriter(w StreamWriter) StreamWriter {
+	return &groupingStreamWriter{w: w}
+}
+
+// groupingStreamWriter collects findings until Close since findings of the
+// same secret can be found at any point of a scan. Suppressed findings are
+// passed on as they are, they are not leaks to group.
+type groupingStreamWriter struct {
+	w        StreamWriter
+	findings []Finding
+}
+
+func (g *groupingStreamWriter) WriteFinding(f Finding) error {
+	if f.Suppression != nil {
+		return g.w.WriteFinding(f)
+	}
+	g.findings = append(g.findings, f)
+	return nil
+}
+
+func (g *groupingStreamWriter) MarkIncomplete() {
+	g.w.MarkIncomplete()
+}
+
+func (g *groupingStreamWriter) Close() error {
+	for _, f := range GroupBySecret(g.findings) {
+		if err := g.w.WriteFinding(f); err != nil {
+			g.w.Close()
+			return err
+		}
+	}
+	return g.w.Close()
+}
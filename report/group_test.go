@@ -0,0 +1,106 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zricethezav/gitleaks/v8/config"
+)
+
+func TestGroupBySecret(t *testing.T) {
+	findings := []Finding{
+		{RuleID: "test-rule", Secret: "a secret", Commit: "c3", File: "b.py", StartLine: 3, Date: "2023-03-01T00:00:00Z"},
+		{RuleID: "test-rule", Secret: "another secret", Commit: "c3", File: "b.py", StartLine: 4, Date: "2023-03-01T00:00:00Z"},
+		{RuleID: "test-rule", Secret: "a secret", Commit: "c1", File: "a.py", StartLine: 1, Date: "2023-01-01T00:00:00Z"},
+		{RuleID: "other-rule", Secret: "a secret", Commit: "c2", File: "a.py", StartLine: 1, Date: "2023-02-01T00:00:00Z"},
+		{RuleID: "test-rule", Secret: "a secret", Commit: "c2", File: "a.py", StartLine: 2, Date: "2023-02-01T00:00:00Z"},
+	}
+
+	grouped := GroupBySecret(findings)
+	assert.Len(t, grouped, 3)
+
+	// the first commit that introduced the secret describes the group
+	assert.Equal(t, "c1", grouped[0].Commit)
+	assert.Equal(t, []Occurrence{
+		{Commit: "c1", File: "a.py", StartLine: 1, Date: "2023-01-01T00:00:00Z"},
+		{Commit: "c2", File: "a.py", StartLine: 2, Date: "2023-02-01T00:00:00Z"},
+		{Commit: "c3", File: "b.py", StartLine: 3, Date: "2023-03-01T00:00:00Z"},
+	}, grouped[0].Occurrences)
+	assert.Equal(t, 3, grouped[0].OccurrenceCount)
+	assert.Equal(t, 3, grouped[0].CommitCount)
+	assert.Equal(t, 2, grouped[0].FileCount)
+
+	assert.Equal(t, "another secret", grouped[1].Secret)
+	assert.Equal(t, 1, grouped[1].OccurrenceCount)
+	assert.Equal(t, "other-rule", grouped[2].RuleID)
+	assert.Equal(t, 1, grouped[2].OccurrenceCount)
+
+	// redacted findings are still grouped by their secret
+	for i := range findings {
+		findings[i].Redact()
+	}
+	assert.Len(t, GroupBySecret(findings), 3)
+
+	// the counter counts the same groups without keeping the findings
+	var counter GroupCounter
+	groups := 0
+	for _, f := range findings {
+		groups = counter.Add(f)
+	}
+	assert.Equal(t, 3, groups)
+}
+
+func TestGroupingStreamWriter(t *testing.T) {
+	findings := []Finding{
+		{RuleID: "test-rule", Secret: "a secret", Commit: "c2", File: "auth.py", Date: "2023-02-01T00:00:00Z"},
+		{RuleID: "test-rule", Secret: "a secret", Commit: "c1", File: "auth.py", Date: "2023-01-01T00:00:00Z"},
+	}
+
+	for _, ext := range []string{"json", "csv", "sarif"} {
+		reportPath := filepath.Join(tmpPath, "grouped."+ext)
+		w, err := NewStreamWriter(config.Config{}, ext, reportPath, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = NewGroupingStreamWriter(w)
+		for _, f := range findings {
+			if err = w.WriteFinding(f); err != nil {
+				t.Error(err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Error(err)
+		}
+		got, err := os.ReadFile(reportPath)
+		os.Remove(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch ext {
+		case "json":
+			var grouped []Finding
+			if err = json.Unmarshal(got, &grouped); err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, grouped, 1)
+			assert.Equal(t, "c1", grouped[0].Commit)
+			assert.Equal(t, 2, grouped[0].OccurrenceCount)
+		case "csv":
+			assert.Contains(t, string(got), ",2,2,1,c1:auth.py:0;c2:auth.py:0,false,,\n")
+		case "sarif":
+			var sarif Sarif
+			if err = json.Unmarshal(got, &sarif); err != nil {
+				t.Fatal(err)
+			}
+			results := sarif.Runs[0].Results
+			assert.Len(t, results, 1)
+			assert.Len(t, results[0].Locations, 2)
+			assert.Equal(t, 2, results[0].Properties.OccurrenceCount)
+		}
+	}
+}
@@ -3,13 +3,142 @@ package report
 import (
 	"encoding/json"
 	"io"
+	"os"
 )
 
+// JsonReport is the layout of a json report written for a scan that was
+// stopped before it finished or that suppressed findings with gitleaks:allow
+// comments. Other reports are a plain array of findings.
+type JsonReport struct {
+	Incomplete bool `json:",omitempty"`
+	Findings   []Finding
+
+	// Suppressed is the suppressed findings section of the report
+	Suppressed []Finding `json:",omitempty"`
+}
+
 func writeJson(findings []Finding, w io.WriteCloser) error {
+	findings, suppressed := splitSuppressed(findings)
 	if len(findings) == 0 {
 		findings = []Finding{}
 	}
 	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", " ")
+	if len(suppressed) != 0 {
+		return encoder.Encode(JsonReport{Findings: findings, Suppressed: suppressed})
+	}
 	return encoder.Encode(findings)
 }
+
+// jsonStreamWriter writes findings to a json report as they are found.
+// The output is identical to what writeJson produces. Suppressed findings
+// are kept until Close and written to the Suppressed section of the report.
+type jsonStreamWriter struct {
+	w          io.WriteCloser
+	count      int
+	incomplete bool
+	suppressed []Finding
+
+	// reportPath is needed to rewrite the report if the scan is
+	// incomplete or findings were suppressed
+	reportPath string
+}
+
+func (j *jsonStreamWriter) WriteFinding(f Finding) error {
+	if f.Suppression != nil {
+		j.suppressed = append(j.suppressed, f)
+		return nil
+	}
+	b, err := json.MarshalIndent(f, " ", " ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n "
+	if j.count == 0 {
+		sep = "[\n "
+	}
+	if _, err = io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	if _, err = j.w.Write(b); err != nil {
+		return err
+	}
+	j.count++
+	return nil
+}
+
+func (j *jsonStreamWriter) MarkIncomplete() {
+	j.incomplete = true
+}
+
+func (j *jsonStreamWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := io.WriteString(j.w, end); err != nil {
+		j.w.Close()
+		return err
+	}
+	if err := j.w.Close(); err != nil {
+		return err
+	}
+	if j.incomplete || len(j.suppressed) != 0 {
+		return rewriteJsonReport(j.reportPath, j.incomplete, j.suppressed)
+	}
+	return nil
+}
+
+// rewriteJsonReport rewrites the findings array of a json report as a
+// JsonReport. The findings are copied rather than decoded so memory
+// stays bounded for large reports.
+func rewriteJsonReport(reportPath string, incomplete bool, suppressed []Finding) error {
+	partialPath := reportPath + ".partial"
+	if err := os.Rename(reportPath, partialPath); err != nil {
+		return err
+	}
+	defer os.Remove(partialPath)
+
+	src, err := os.Open(partialPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	header := "{\n \"Findings\": "
+	if incomplete {
+		header = "{\n \"Incomplete\": true,\n \"Findings\": "
+	}
+	if _, err = io.WriteString(dst, header); err != nil {
+		dst.Close()
+		return err
+	}
+	// copy the findings array without its trailing newline
+	fileInfo, err := src.Stat()
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err = io.CopyN(dst, src, fileInfo.Size()-1); err != nil {
+		dst.Close()
+		return err
+	}
+	footer := "\n}\n"
+	if len(suppressed) != 0 {
+		b, err := json.MarshalIndent(suppressed, " ", " ")
+		if err != nil {
+			dst.Close()
+			return err
+		}
+		footer = ",\n \"Suppressed\": " + string(b) + footer
+	}
+	if _, err = io.WriteString(dst, footer); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
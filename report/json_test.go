@@ -34,6 +34,7 @@ func TestWriteJSON(t *testing.T) {
 					Author:      "John Doe",
 					Email:       "johndoe@gmail.com",
 					Date:        "10-19-2003",
+					Fingerprint: "0000000000000000:auth.py:test-rule:1",
 					Tags:        []string{},
 				},
 			}},
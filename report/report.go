@@ -1,6 +1,8 @@
 package report
 
 import (
+	"encoding/csv"
+	"fmt"
 	"os"
 	"strings"
 
@@ -30,3 +32,67 @@ func Write(findings []Finding, cfg config.Config, ext string, reportPath string)
 
 	return err
 }
+
+// splitSuppressed separates the findings suppressed by gitleaks:allow
+// comments from the other findings
+func splitSuppressed(findings []Finding) ([]Finding, []Finding) {
+	var leaks, suppressed []Finding
+	for _, f := range findings {
+		if f.Suppression != nil {
+			suppressed = append(suppressed, f)
+		} else {
+			leaks = append(leaks, f)
+		}
+	}
+	return leaks, suppressed
+}
+
+// StreamWriter writes findings to a report as they are produced by a scan.
+// Findings suppressed by gitleaks:allow comments, those with a Suppression,
+// are written to the report as such rather than as leaks. Close must be
+// called once the scan is done to complete the report.
+type StreamWriter interface {
+	WriteFinding(f Finding) error
+
+	// MarkIncomplete records that the scan was stopped before it
+	// finished. It must be called before Close.
+	MarkIncomplete()
+
+	Close() error
+}
+
+// Options describes how the scan whose findings are reported was run. Fields
+// of findings that only some scans set are left out of the report otherwise.
+type Options struct {
+	// Verify is set if secrets were verified, see Finding.Status
+	Verify bool
+
+	// CheckHead is set if the secrets of git findings were checked
+	// against the default branch, see Finding.HeadStatus
+	CheckHead bool
+}
+
+// NewStreamWriter creates the report file at reportPath and returns a
+// StreamWriter for the given report format. json and csv reports are
+// written incrementally, sarif reports are written on Close.
+func NewStreamWriter(cfg config.Config, ext string, reportPath string, opts Options) (StreamWriter, error) {
+	ext = strings.ToLower(ext)
+	switch ext {
+	case ".json", "json", ".csv", "csv", ".sarif", "sarif":
+	default:
+		return nil, fmt.Errorf("unsupported report format %s", ext)
+	}
+
+	file, err := os.Create(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	switch ext {
+	case ".json", "json":
+		return &jsonStreamWriter{w: file, reportPath: reportPath}, nil
+	case ".csv", "csv":
+		return &csvStreamWriter{w: file, cw: csv.NewWriter(file), opts: opts}, nil
+	default:
+		return &sarifStreamWriter{cfg: cfg, w: file}, nil
+	}
+}
@@ -1,11 +1,16 @@
 package report
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/zricethezav/gitleaks/v8/config"
 )
 
@@ -109,3 +114,251 @@ func TestReport(t *testing.T) {
 		}
 	}
 }
+
+func TestStreamWriter(t *testing.T) {
+	tests := []struct {
+		findings  []Finding
+		ext       string
+		expected  string
+		wantEmpty bool
+		wantError bool
+	}{
+		{
+			ext:      "json",
+			expected: filepath.Join(expectPath, "report", "json_simple.json"),
+			findings: []Finding{
+				{
+					Description: "",
+					RuleID:      "test-rule",
+					Match:       "line containing secret",
+					Secret:      "a secret",
+					StartLine:   1,
+					EndLine:     2,
+					StartColumn: 1,
+					EndColumn:   2,
+					Message:     "opps",
+					File:        "auth.py",
+					Commit:      "0000000000000000",
+					Author:      "John Doe",
+					Email:       "johndoe@gmail.com",
+					Date:        "10-19-2003",
+					Fingerprint: "0000000000000000:auth.py:test-rule:1",
+					Tags:        []string{},
+				},
+			},
+		},
+		{
+			ext:      ".json",
+			expected: filepath.Join(expectPath, "report", "empty.json"),
+			findings: []Finding{},
+		},
+		{
+			ext:      "CSV",
+			expected: filepath.Join(expectPath, "report", "csv_simple.csv"),
+			findings: []Finding{
+				{
+					RuleID:      "test-rule",
+					Match:       "line containing secret",
+					Secret:      "a secret",
+					StartLine:   1,
+					EndLine:     2,
+					StartColumn: 1,
+					EndColumn:   2,
+					Message:     "opps",
+					File:        "auth.py",
+					Commit:      "0000000000000000",
+					Author:      "John Doe",
+					Email:       "johndoe@gmail.com",
+					Date:        "10-19-2003",
+					Fingerprint: "0000000000000000:auth.py:test-rule:1",
+				},
+			},
+		},
+		{
+			ext:       "csv",
+			findings:  []Finding{},
+			wantEmpty: true,
+		},
+		{
+			ext:       ".jsonj",
+			wantError: true,
+		},
+	}
+
+	for i, test := range tests {
+		reportPath := filepath.Join(tmpPath, "stream"+strconv.Itoa(i)+test.ext)
+		w, err := NewStreamWriter(config.Config{}, test.ext, reportPath, Options{})
+		if test.wantError {
+			if err == nil {
+				t.Errorf("expected error for extension %s", test.ext)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range test.findings {
+			if err = w.WriteFinding(f); err != nil {
+				t.Error(err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Error(err)
+		}
+		got, err := os.ReadFile(reportPath)
+		os.Remove(reportPath)
+		if err != nil {
+			t.Error(err)
+		}
+		if test.wantEmpty {
+			if len(got) > 0 {
+				t.Errorf("Expected empty file, got %s", got)
+			}
+			continue
+		}
+		want, err := os.ReadFile(test.expected)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("got %s, want %s", string(got), string(want))
+		}
+	}
+}
+
+func TestStreamWriterIncomplete(t *testing.T) {
+	findings := []Finding{
+		{
+			RuleID:      "test-rule",
+			Secret:      "a secret",
+			File:        "auth.py",
+			Fingerprint: "auth.py:test-rule:0000000000000000",
+		},
+	}
+
+	reportPath := filepath.Join(tmpPath, "incomplete.json")
+	w, err := NewStreamWriter(config.Config{}, "json", reportPath, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		if err = w.WriteFinding(f); err != nil {
+			t.Error(err)
+		}
+	}
+	w.MarkIncomplete()
+	if err = w.Close(); err != nil {
+		t.Error(err)
+	}
+	got, err := os.ReadFile(reportPath)
+	os.Remove(reportPath)
+	if err != nil {
+		t.Error(err)
+	}
+	var incompleteReport JsonReport
+	if err = json.Unmarshal(got, &incompleteReport); err != nil {
+		t.Fatalf("incomplete json report is not valid json: %s\n%s", err, got)
+	}
+	assert.True(t, incompleteReport.Incomplete)
+	assert.Equal(t, findings, incompleteReport.Findings)
+
+	reportPath = filepath.Join(tmpPath, "incomplete.sarif")
+	w, err = NewStreamWriter(config.Config{}, "sarif", reportPath, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.MarkIncomplete()
+	if err = w.Close(); err != nil {
+		t.Error(err)
+	}
+	got, err = os.ReadFile(reportPath)
+	os.Remove(reportPath)
+	if err != nil {
+		t.Error(err)
+	}
+	var sarif Sarif
+	if err = json.Unmarshal(got, &sarif); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, sarif.Runs[0].Invocations[0].ExecutionSuccessful)
+}
+
+func TestStreamWriterSuppressed(t *testing.T) {
+	// the findings suppressed by gitleaks:allow comments are written to
+	// the report apart from the other findings
+	findings := []Finding{
+		{
+			RuleID:      "test-rule",
+			Secret:      "a test secret",
+			File:        "auth_test.py",
+			Fingerprint: "auth_test.py:test-rule:0000000000000000",
+			Suppression: &Suppression{
+				Reason: "test fixture",
+				Until:  "2027-01-01",
+			},
+		},
+		{
+			RuleID:      "test-rule",
+			Secret:      "a secret",
+			File:        "auth.py",
+			Fingerprint: "auth.py:test-rule:1111111111111111",
+		},
+	}
+	write := func(ext string) []byte {
+		reportPath := filepath.Join(tmpPath, "suppressed."+ext)
+		w, err := NewStreamWriter(config.Config{}, ext, reportPath, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range findings {
+			if err = w.WriteFinding(f); err != nil {
+				t.Error(err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Error(err)
+		}
+		got, err := os.ReadFile(reportPath)
+		os.Remove(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	got := write("json")
+	var jsonReport JsonReport
+	if err := json.Unmarshal(got, &jsonReport); err != nil {
+		t.Fatalf("json report is not valid json: %s\n%s", err, got)
+	}
+	assert.False(t, jsonReport.Incomplete)
+	assert.Equal(t, findings[1:], jsonReport.Findings)
+	assert.Equal(t, findings[:1], jsonReport.Suppressed)
+
+	got = write("csv")
+	records, err := csv.NewReader(bytes.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, records, 3)
+	header := records[0]
+	suppressed := make(map[string]string)
+	for i, column := range header {
+		suppressed[column] = records[2][i]
+	}
+	assert.Equal(t, "auth.py", records[1][2])
+	assert.Equal(t, "auth_test.py", suppressed["File"])
+	assert.Equal(t, "true", suppressed["Suppressed"])
+	assert.Equal(t, "test fixture", suppressed["SuppressionReason"])
+	assert.Equal(t, "2027-01-01", suppressed["SuppressionUntil"])
+
+	got = write("sarif")
+	var sarif Sarif
+	if err = json.Unmarshal(got, &sarif); err != nil {
+		t.Fatal(err)
+	}
+	results := sarif.Runs[0].Results
+	assert.Len(t, results, 2)
+	assert.Equal(t, []Suppressions{{Kind: "inSource", Justification: "test fixture"}}, results[0].Suppressions)
+	assert.Empty(t, results[1].Suppressions)
+}
@@ -9,10 +9,16 @@ import (
 )
 
 func writeSarif(cfg config.Config, findings []Finding, w io.WriteCloser) error {
+	return writeSarifRun(cfg, findings, true, w)
+}
+
+// writeSarifRun writes a sarif report. executionSuccessful is false if the
+// scan was stopped before it finished.
+func writeSarifRun(cfg config.Config, findings []Finding, executionSuccessful bool, w io.WriteCloser) error {
 	sarif := Sarif{
 		Schema:  "https://schemastore.azurewebsites.net/schemas/json/sarif-2.1.0-rtm.5.json",
 		Version: "2.1.0",
-		Runs:    getRuns(cfg, findings),
+		Runs:    getRuns(cfg, findings, executionSuccessful),
 	}
 
 	encoder := json.NewEncoder(w)
@@ -20,11 +26,42 @@ func writeSarif(cfg config.Config, findings []Finding, w io.WriteCloser) error {
 	return encoder.Encode(sarif)
 }
 
-func getRuns(cfg config.Config, findings []Finding) []Runs {
+// sarifStreamWriter collects findings and writes the sarif report on Close
+// since a sarif document can only be written once all results are known.
+type sarifStreamWriter struct {
+	cfg        config.Config
+	w          io.WriteCloser
+	findings   []Finding
+	incomplete bool
+}
+
+func (s *sarifStreamWriter) WriteFinding(f Finding) error {
+	s.findings = append(s.findings, f)
+	return nil
+}
+
+func (s *sarifStreamWriter) MarkIncomplete() {
+	s.incomplete = true
+}
+
+func (s *sarifStreamWriter) Close() error {
+	if err := writeSarifRun(s.cfg, s.findings, !s.incomplete, s.w); err != nil {
+		s.w.Close()
+		return err
+	}
+	return s.w.Close()
+}
+
+func getRuns(cfg config.Config, findings []Finding, executionSuccessful bool) []Runs {
 	return []Runs{
 		{
 			Tool:    getTool(cfg),
 			Results: getResults(findings),
+			Invocations: []Invocation{
+				{
+					ExecutionSuccessful: executionSuccessful,
+				},
+			},
 		},
 	}
 }
@@ -92,15 +129,24 @@ func getResults(findings []Finding) []Results {
 				Text: messageText(f),
 			},
 			RuleId:    f.RuleID,
-			Locations: getLocation(f),
-			// This information goes in partial fingerprings until revision
-			// data can be added somewhere else
+			Locations: getLocations(f),
 			PartialFingerPrints: PartialFingerPrints{
-				CommitSha:     f.Commit,
-				Email:         f.Email,
-				CommitMessage: f.Message,
-				Date:          f.Date,
-				Author:        f.Author,
+				GitleaksFingerprint: f.Fingerprint,
+			},
+			Suppressions: getSuppressions(f),
+			Properties: Properties{
+				CommitSha:       f.Commit,
+				Email:           f.Email,
+				CommitMessage:   f.Message,
+				Date:            f.Date,
+				Author:          f.Author,
+				Verified:        f.Verified,
+				Status:          f.Status,
+				HeadStatus:      f.HeadStatus,
+				RemovedIn:       f.RemovedIn,
+				OccurrenceCount: f.OccurrenceCount,
+				CommitCount:     f.CommitCount,
+				FileCount:       f.FileCount,
 			},
 		}
 		results = append(results, r)
@@ -108,6 +154,25 @@ func getResults(findings []Finding) []Results {
 	return results
 }
 
+// getLocations returns the location of a finding, or the locations of all
+// occurrences of a grouped finding
+func getLocations(f Finding) []Locations {
+	if len(f.Occurrences) == 0 {
+		return getLocation(f)
+	}
+	var locations []Locations
+	for _, o := range f.Occurrences {
+		occurrence := f
+		occurrence.File = o.File
+		occurrence.StartLine = o.StartLine
+		occurrence.EndLine = o.EndLine
+		occurrence.StartColumn = o.StartColumn
+		occurrence.EndColumn = o.EndColumn
+		locations = append(locations, getLocation(occurrence)...)
+	}
+	return locations
+}
+
 func getLocation(f Finding) []Locations {
 	return []Locations{
 		{
@@ -130,11 +195,40 @@ func getLocation(f Finding) []Locations {
 }
 
 type PartialFingerPrints struct {
+	GitleaksFingerprint string `json:"gitleaksFingerprint/v1"`
+}
+
+// getSuppressions returns the suppression of a finding suppressed by an
+// inline gitleaks:allow comment
+func getSuppressions(f Finding) []Suppressions {
+	if f.Suppression == nil {
+		return nil
+	}
+	return []Suppressions{
+		{
+			Kind:          "inSource",
+			Justification: f.Suppression.Reason,
+		},
+	}
+}
+
+// Properties is the SARIF property bag used to carry
+// revision, verification and HEAD data about a result
+type Properties struct {
 	CommitSha     string `json:"commitSha"`
 	Email         string `json:"email"`
 	Author        string `json:"author"`
 	Date          string `json:"date"`
 	CommitMessage string `json:"commitMessage"`
+	Verified      bool   `json:"verified,omitempty"`
+	Status        string `json:"status,omitempty"`
+	HeadStatus    string `json:"headStatus,omitempty"`
+	RemovedIn     string `json:"removedIn,omitempty"`
+
+	// counts of a finding grouped by secret
+	OccurrenceCount int `json:"occurrenceCount,omitempty"`
+	CommitCount     int `json:"commitCount,omitempty"`
+	FileCount       int `json:"fileCount,omitempty"`
 }
 
 type Sarif struct {
@@ -197,13 +291,25 @@ type Locations struct {
 }
 
 type Results struct {
-	Message             Message     `json:"message"`
-	RuleId              string      `json:"ruleId"`
-	Locations           []Locations `json:"locations"`
-	PartialFingerPrints `json:"partialFingerprints"`
+	Message             Message             `json:"message"`
+	RuleId              string              `json:"ruleId"`
+	Locations           []Locations         `json:"locations"`
+	PartialFingerPrints PartialFingerPrints `json:"partialFingerprints"`
+	Suppressions        []Suppressions      `json:"suppressions,omitempty"`
+	Properties          Properties          `json:"properties"`
+}
+
+type Suppressions struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type Invocation struct {
+	ExecutionSuccessful bool `json:"executionSuccessful"`
 }
 
 type Runs struct {
-	Tool    Tool      `json:"tool"`
-	Results []Results `json:"results"`
+	Tool        Tool         `json:"tool"`
+	Results     []Results    `json:"results"`
+	Invocations []Invocation `json:"invocations"`
 }
@@ -0,0 +1,152 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Status is the result of verifying a secret
+type Status string
+
+const (
+	// Verified means the provider accepted the secret, it is live
+	Verified Status = "verified"
+
+	// Invalid means the provider rejected the secret, it has been revoked
+	// or was never valid
+	Invalid Status = "invalid"
+
+	// Unknown means the secret could not be checked, for example because
+	// the provider could not be reached
+	Unknown Status = "unknown"
+)
+
+// Verifier checks whether a secret found by a rule is live
+type Verifier interface {
+	Verify(ctx context.Context, secret string) (Status, error)
+}
+
+// HTTPVerifier verifies a secret by sending an authenticated request to
+// Endpoint. A 2xx response means the secret is live, 401 and 403 mean it is
+// not. Any other response leaves the status unknown.
+type HTTPVerifier struct {
+	// Method is the HTTP method of the request, GET if empty
+	Method string
+
+	// Endpoint is the URL of the request
+	Endpoint string
+
+	// Header returns the headers that authenticate a request with secret
+	Header func(secret string) http.Header
+
+	// Client sends the request, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Verify sends secret to the endpoint and returns the resulting status
+func (v *HTTPVerifier) Verify(ctx context.Context, secret string) (Status, error) {
+	method := v.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.Endpoint, nil)
+	if err != nil {
+		return Unknown, err
+	}
+	req.Header = v.Header(secret)
+	req.Header.Set("User-Agent", "gitleaks")
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Unknown, err
+	}
+	// the body is not used, but it is read to the end so the connection
+	// can be reused for the next secret
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Verified, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return Invalid, nil
+	default:
+		return Unknown, fmt.Errorf("unexpected response from %s: %s", v.Endpoint, resp.Status)
+	}
+}
+
+// NewVerifiers returns the built-in verifiers keyed by the ID of the rule whose
+// secrets they check. endpoints overrides the endpoint of the verifier for a
+// rule ID, e.g. to point it at a mock server. It is an error to override the
+// endpoint of a rule that has no built-in verifier.
+func NewVerifiers(endpoints map[string]string) (map[string]Verifier, error) {
+	verifiers := make(map[string]Verifier)
+	builtin := builtinVerifiers()
+	for ruleID, v := range builtin {
+		verifiers[ruleID] = v
+	}
+
+	var unknown []string
+	for ruleID, endpoint := range endpoints {
+		v, ok := builtin[ruleID]
+		if !ok {
+			unknown = append(unknown, ruleID)
+			continue
+		}
+		v.Endpoint = endpoint
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("no verifier for rule(s) %s", strings.Join(unknown, ", "))
+	}
+	return verifiers, nil
+}
+
+// builtinVerifiers returns new instances of the built-in verifiers so
+// overriding an endpoint does not affect other detectors
+func builtinVerifiers() map[string]*HTTPVerifier {
+	githubUser := &HTTPVerifier{
+		Endpoint: "https://api.github.com/user",
+		Header:   authHeader("Authorization", "token "),
+	}
+	githubOAuth := *githubUser
+	return map[string]*HTTPVerifier{
+		"github-pat":   githubUser,
+		"github-oauth": &githubOAuth,
+		"gitlab-pat": {
+			Endpoint: "https://gitlab.com/api/v4/user",
+			Header:   authHeader("PRIVATE-TOKEN", ""),
+		},
+		"npm-access-token": {
+			Endpoint: "https://registry.npmjs.org/-/whoami",
+			Header:   authHeader("Authorization", "Bearer "),
+		},
+		"sendgrid-api-token": {
+			Endpoint: "https://api.sendgrid.com/v3/scopes",
+			Header:   authHeader("Authorization", "Bearer "),
+		},
+		"stripe-access-token": {
+			Endpoint: "https://api.stripe.com/v1/balance",
+			Header:   authHeader("Authorization", "Bearer "),
+		},
+	}
+}
+
+// authHeader returns a Header function that sets key to prefix+secret
+func authHeader(key string, prefix string) func(string) http.Header {
+	return func(secret string) http.Header {
+		header := make(http.Header)
+		header.Set(key, prefix+secret)
+		return header
+	}
+}
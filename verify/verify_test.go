@@ -0,0 +1,63 @@
+package verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "token live":
+			w.WriteHeader(http.StatusOK)
+		case "token revoked":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		secret      string
+		status      Status
+		expectedErr bool
+	}{
+		{secret: "live", status: Verified},
+		{secret: "revoked", status: Invalid},
+		{secret: "broken", status: Unknown, expectedErr: true},
+	}
+
+	verifier := &HTTPVerifier{
+		Endpoint: server.URL,
+		Header:   authHeader("Authorization", "token "),
+	}
+	for _, tt := range tests {
+		status, err := verifier.Verify(context.Background(), tt.secret)
+		assert.Equal(t, tt.status, status)
+		assert.Equal(t, tt.expectedErr, err != nil)
+	}
+}
+
+func TestNewVerifiers(t *testing.T) {
+	verifiers, err := NewVerifiers(map[string]string{
+		"github-pat": "http://localhost:8080/user",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/user", verifiers["github-pat"].(*HTTPVerifier).Endpoint)
+	assert.Equal(t, "https://api.github.com/user", verifiers["github-oauth"].(*HTTPVerifier).Endpoint)
+
+	// overrides do not leak into other sets of verifiers
+	verifiers, err = NewVerifiers(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.github.com/user", verifiers["github-pat"].(*HTTPVerifier).Endpoint)
+
+	_, err = NewVerifiers(map[string]string{
+		"no-such-rule": "http://localhost:8080",
+	})
+	assert.Error(t, err)
+}